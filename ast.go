@@ -20,6 +20,10 @@ type Visitor func(Node) any
 // Node in the AST which is visitable.
 type Node interface {
 	Accept(Visitor) any
+	// Pos of the first character of the node in the source.
+	Pos() Position
+	// End just past the last character of the node in the source.
+	End() Position
 }
 
 type Stmt interface {
@@ -30,70 +34,170 @@ type Stmt interface {
 
 type (
 	PrintStmt struct {
-		expr Expr
+		keyword   Token
+		expr      Expr
+		semicolon Token
 	}
 
 	ExprStmt struct {
-		expr Expr
+		expr      Expr
+		semicolon Token
 	}
 
 	FuncStmt struct {
-		name   Token
-		params []Token
+		// keyword is 'fun', or the '(' of an arrow function.
+		// Unset for methods.
+		keyword Token
+		name    Token
+		params  []Token
 		// Does this need to be a slice?
 		body []Stmt
 	}
 
 	VarStmt struct {
-		name Token
-		init Expr
+		keyword   Token
+		name      Token
+		init      Expr
+		semicolon Token
 	}
 
 	BlockStmt struct {
+		lbrace     Token
 		statements []Stmt
+		rbrace     Token
 	}
 
 	IfStmt struct {
+		keyword                Token
 		cond                   Expr
 		thenBranch, elseBranch Stmt
 	}
 
 	WhileStmt struct {
-		cond Expr
-		body Stmt
+		keyword Token
+		cond    Expr
+		body    Stmt
+		// incr of a for loop, run after the body even on continue.
+		incr Expr
+	}
+
+	BreakStmt struct {
+		keyword   Token
+		semicolon Token
+	}
+
+	ContinueStmt struct {
+		keyword   Token
+		semicolon Token
 	}
 
 	ReturnStmt struct {
+		keyword   Token
+		value     Expr
+		semicolon Token
+	}
+
+	ThrowStmt struct {
+		keyword   Token
+		value     Expr
+		semicolon Token
+	}
+
+	// TryStmt has a catch, a finally or both.
+	TryStmt struct {
 		keyword Token
-		value   Expr
+		body    *BlockStmt
+		// name the catch binds the error to.
+		name    Token
+		catch   *BlockStmt
+		finally *BlockStmt
 	}
 
 	ClassStmt struct {
+		keyword Token
 		name    Token
 		super   *Variable // Why can it not be a token, looked up by name?
 		methods []Stmt
+		rbrace  Token
 	}
 )
 
-func (s *PrintStmt) Accept(v Visitor) any  { return v(s) }
-func (s *ExprStmt) Accept(v Visitor) any   { return v(s) }
-func (s *FuncStmt) Accept(v Visitor) any   { return v(s) }
-func (s *VarStmt) Accept(v Visitor) any    { return v(s) }
-func (s *BlockStmt) Accept(v Visitor) any  { return v(s) }
-func (s *IfStmt) Accept(v Visitor) any     { return v(s) }
-func (s *WhileStmt) Accept(v Visitor) any  { return v(s) }
-func (s *ReturnStmt) Accept(v Visitor) any { return v(s) }
-func (s *ClassStmt) Accept(v Visitor) any  { return v(s) }
-
-func (s *PrintStmt) Stmt() Expr  { return s.expr }
-func (s *ExprStmt) Stmt() Expr   { return s.expr }
-func (s *FuncStmt) Stmt() Expr   { return nil }
-func (s *VarStmt) Stmt() Expr    { return s.init }
-func (s *BlockStmt) Stmt() Expr  { return nil }
-func (s *IfStmt) Stmt() Expr     { return nil }
-func (s *WhileStmt) Stmt() Expr  { return nil }
-func (s *ReturnStmt) Stmt() Expr { return nil }
-func (s *ClassStmt) Stmt() Expr  { return nil }
+func (s *PrintStmt) Accept(v Visitor) any    { return v(s) }
+func (s *ExprStmt) Accept(v Visitor) any     { return v(s) }
+func (s *FuncStmt) Accept(v Visitor) any     { return v(s) }
+func (s *VarStmt) Accept(v Visitor) any      { return v(s) }
+func (s *BlockStmt) Accept(v Visitor) any    { return v(s) }
+func (s *IfStmt) Accept(v Visitor) any       { return v(s) }
+func (s *WhileStmt) Accept(v Visitor) any    { return v(s) }
+func (s *BreakStmt) Accept(v Visitor) any    { return v(s) }
+func (s *ContinueStmt) Accept(v Visitor) any { return v(s) }
+func (s *ReturnStmt) Accept(v Visitor) any   { return v(s) }
+func (s *ThrowStmt) Accept(v Visitor) any    { return v(s) }
+func (s *TryStmt) Accept(v Visitor) any      { return v(s) }
+func (s *ClassStmt) Accept(v Visitor) any    { return v(s) }
+
+func (s *PrintStmt) Pos() Position    { return s.keyword.Pos() }
+func (s *ExprStmt) Pos() Position     { return s.expr.Pos() }
+func (s *VarStmt) Pos() Position      { return s.keyword.Pos() }
+func (s *BlockStmt) Pos() Position    { return s.lbrace.Pos() }
+func (s *IfStmt) Pos() Position       { return s.keyword.Pos() }
+func (s *WhileStmt) Pos() Position    { return s.keyword.Pos() }
+func (s *BreakStmt) Pos() Position    { return s.keyword.Pos() }
+func (s *ContinueStmt) Pos() Position { return s.keyword.Pos() }
+func (s *ReturnStmt) Pos() Position   { return s.keyword.Pos() }
+func (s *ThrowStmt) Pos() Position    { return s.keyword.Pos() }
+func (s *TryStmt) Pos() Position      { return s.keyword.Pos() }
+func (s *ClassStmt) Pos() Position    { return s.keyword.Pos() }
+func (s *FuncStmt) Pos() Position {
+	if s.keyword.Kind != ILLEGAL {
+		return s.keyword.Pos()
+	}
+	return s.name.Pos()
+}
+
+func (s *PrintStmt) End() Position    { return s.semicolon.EndPos() }
+func (s *ExprStmt) End() Position     { return s.semicolon.EndPos() }
+func (s *FuncStmt) End() Position     { return s.body[len(s.body)-1].End() }
+func (s *VarStmt) End() Position      { return s.semicolon.EndPos() }
+func (s *BlockStmt) End() Position    { return s.rbrace.EndPos() }
+func (s *WhileStmt) End() Position    { return s.body.End() }
+func (s *BreakStmt) End() Position    { return s.semicolon.EndPos() }
+func (s *ContinueStmt) End() Position { return s.semicolon.EndPos() }
+func (s *ThrowStmt) End() Position    { return s.semicolon.EndPos() }
+func (s *ReturnStmt) End() Position {
+	// The return of an arrow function has no semicolon.
+	if s.semicolon.Kind == ILLEGAL {
+		return s.value.End()
+	}
+	return s.semicolon.EndPos()
+}
+func (s *TryStmt) End() Position {
+	if s.finally != nil {
+		return s.finally.End()
+	}
+	return s.catch.End()
+}
+func (s *ClassStmt) End() Position { return s.rbrace.EndPos() }
+func (s *IfStmt) End() Position {
+	if s.elseBranch != nil {
+		return s.elseBranch.End()
+	}
+	return s.thenBranch.End()
+}
+
+func (s *PrintStmt) Stmt() Expr    { return s.expr }
+func (s *ExprStmt) Stmt() Expr     { return s.expr }
+func (s *FuncStmt) Stmt() Expr     { return nil }
+func (s *VarStmt) Stmt() Expr      { return s.init }
+func (s *BlockStmt) Stmt() Expr    { return nil }
+func (s *IfStmt) Stmt() Expr       { return nil }
+func (s *WhileStmt) Stmt() Expr    { return nil }
+func (s *BreakStmt) Stmt() Expr    { return nil }
+func (s *ContinueStmt) Stmt() Expr { return nil }
+func (s *ReturnStmt) Stmt() Expr   { return nil }
+func (s *ThrowStmt) Stmt() Expr    { return nil }
+func (s *TryStmt) Stmt() Expr      { return nil }
+func (s *ClassStmt) Stmt() Expr    { return nil }
 
 type Expr interface {
 	Node
@@ -118,11 +222,20 @@ type (
 	}
 
 	Literal struct {
+		tok Token
 		val any
 	}
 
 	Grouping struct {
-		group Expr
+		lparen Token
+		group  Expr
+		rparen Token
+	}
+
+	InterpolationExpr struct {
+		// parts alternate between string Literals and expressions,
+		// starting and ending with a Literal.
+		parts []Expr
 	}
 
 	Variable struct {
@@ -152,6 +265,45 @@ type (
 		value  Expr
 	}
 
+	// ListExpr is a list literal like [1, 2, 3].
+	ListExpr struct {
+		lbracket Token
+		elems    []Expr
+		rbracket Token
+	}
+
+	// MapExpr is a map literal like {"a": 1, b: 2}.
+	MapExpr struct {
+		lbrace Token
+		// keys and values of each entry, a bare name key is a string Literal.
+		keys, values []Expr
+		rbrace       Token
+	}
+
+	IndexExpr struct {
+		// object[index]
+		object   Expr
+		lbracket Token
+		index    Expr
+		rbracket Token
+	}
+
+	SliceExpr struct {
+		// object[lo:hi] where lo and hi are optional.
+		object   Expr
+		lbracket Token
+		lo, hi   Expr
+		rbracket Token
+	}
+
+	IndexSetExpr struct {
+		// object[index] = value
+		object   Expr
+		lbracket Token
+		index    Expr
+		value    Expr
+	}
+
 	ThisExpr struct {
 		keyword Token
 	}
@@ -160,33 +312,204 @@ type (
 		keyword Token
 		method  Token
 	}
+
+	// FuncExpr is an anonymous function, like fun (a) { return a; }
+	// or the arrow function (a) => a.
+	FuncExpr struct {
+		decl *FuncStmt // Without a name.
+		// arrow is the expression an arrow function returns.
+		arrow Expr
+	}
 )
 
-func (e *BinaryExpr) Accept(v Visitor) any  { return v(e) }
-func (e *LogicalExpr) Accept(v Visitor) any { return v(e) }
-func (e *UnaryExpr) Accept(v Visitor) any   { return v(e) }
-func (e *Literal) Accept(v Visitor) any     { return v(e) }
-func (e *Grouping) Accept(v Visitor) any    { return v(e) }
-func (e *Variable) Accept(v Visitor) any    { return v(e) }
-func (e *Assign) Accept(v Visitor) any      { return v(e) }
-func (e *Call) Accept(v Visitor) any        { return v(e) }
-func (e *GetExpr) Accept(v Visitor) any     { return v(e) }
-func (e *SetExpr) Accept(v Visitor) any     { return v(e) }
-func (e *ThisExpr) Accept(v Visitor) any    { return v(e) }
-func (e *SuperExpr) Accept(v Visitor) any   { return v(e) }
-
-func (e *BinaryExpr) expr()  {}
-func (e *LogicalExpr) expr() {}
-func (e *UnaryExpr) expr()   {}
-func (e *Literal) expr()     {}
-func (e *Grouping) expr()    {}
-func (e *Variable) expr()    {}
-func (e *Assign) expr()      {}
-func (e *Call) expr()        {}
-func (e *GetExpr) expr()     {}
-func (e *SetExpr) expr()     {}
-func (e *ThisExpr) expr()    {}
-func (e *SuperExpr) expr()   {}
+func (e *BinaryExpr) Accept(v Visitor) any        { return v(e) }
+func (e *LogicalExpr) Accept(v Visitor) any       { return v(e) }
+func (e *UnaryExpr) Accept(v Visitor) any         { return v(e) }
+func (e *Literal) Accept(v Visitor) any           { return v(e) }
+func (e *Grouping) Accept(v Visitor) any          { return v(e) }
+func (e *InterpolationExpr) Accept(v Visitor) any { return v(e) }
+func (e *Variable) Accept(v Visitor) any          { return v(e) }
+func (e *Assign) Accept(v Visitor) any            { return v(e) }
+func (e *Call) Accept(v Visitor) any              { return v(e) }
+func (e *GetExpr) Accept(v Visitor) any           { return v(e) }
+func (e *SetExpr) Accept(v Visitor) any           { return v(e) }
+func (e *ListExpr) Accept(v Visitor) any          { return v(e) }
+func (e *MapExpr) Accept(v Visitor) any           { return v(e) }
+func (e *IndexExpr) Accept(v Visitor) any         { return v(e) }
+func (e *SliceExpr) Accept(v Visitor) any         { return v(e) }
+func (e *IndexSetExpr) Accept(v Visitor) any      { return v(e) }
+func (e *ThisExpr) Accept(v Visitor) any          { return v(e) }
+func (e *SuperExpr) Accept(v Visitor) any         { return v(e) }
+func (e *FuncExpr) Accept(v Visitor) any          { return v(e) }
+
+func (e *BinaryExpr) Pos() Position        { return e.left.Pos() }
+func (e *LogicalExpr) Pos() Position       { return e.left.Pos() }
+func (e *UnaryExpr) Pos() Position         { return e.op.Pos() }
+func (e *Literal) Pos() Position           { return e.tok.Pos() }
+func (e *Grouping) Pos() Position          { return e.lparen.Pos() }
+func (e *InterpolationExpr) Pos() Position { return e.parts[0].Pos() }
+func (e *Variable) Pos() Position          { return e.name.Pos() }
+func (e *Assign) Pos() Position            { return e.name.Pos() }
+func (e *Call) Pos() Position              { return e.callee.Pos() }
+func (e *GetExpr) Pos() Position           { return e.object.Pos() }
+func (e *SetExpr) Pos() Position           { return e.object.Pos() }
+func (e *ListExpr) Pos() Position          { return e.lbracket.Pos() }
+func (e *MapExpr) Pos() Position           { return e.lbrace.Pos() }
+func (e *IndexExpr) Pos() Position         { return e.object.Pos() }
+func (e *SliceExpr) Pos() Position         { return e.object.Pos() }
+func (e *IndexSetExpr) Pos() Position      { return e.object.Pos() }
+func (e *ThisExpr) Pos() Position          { return e.keyword.Pos() }
+func (e *SuperExpr) Pos() Position         { return e.keyword.Pos() }
+func (e *FuncExpr) Pos() Position          { return e.decl.Pos() }
+
+func (e *BinaryExpr) End() Position        { return e.right.End() }
+func (e *LogicalExpr) End() Position       { return e.right.End() }
+func (e *UnaryExpr) End() Position         { return e.right.End() }
+func (e *Literal) End() Position           { return e.tok.EndPos() }
+func (e *Grouping) End() Position          { return e.rparen.EndPos() }
+func (e *InterpolationExpr) End() Position { return e.parts[len(e.parts)-1].End() }
+func (e *Variable) End() Position          { return e.name.EndPos() }
+func (e *Assign) End() Position            { return e.val.End() }
+func (e *Call) End() Position              { return e.paren.EndPos() }
+func (e *GetExpr) End() Position           { return e.name.EndPos() }
+func (e *SetExpr) End() Position           { return e.value.End() }
+func (e *ListExpr) End() Position          { return e.rbracket.EndPos() }
+func (e *MapExpr) End() Position           { return e.rbrace.EndPos() }
+func (e *IndexExpr) End() Position         { return e.rbracket.EndPos() }
+func (e *SliceExpr) End() Position         { return e.rbracket.EndPos() }
+func (e *IndexSetExpr) End() Position      { return e.value.End() }
+func (e *ThisExpr) End() Position          { return e.keyword.EndPos() }
+func (e *SuperExpr) End() Position         { return e.method.EndPos() }
+func (e *FuncExpr) End() Position {
+	if e.arrow != nil {
+		return e.arrow.End()
+	}
+	return e.decl.End()
+}
+
+func (e *BinaryExpr) expr()        {}
+func (e *LogicalExpr) expr()       {}
+func (e *UnaryExpr) expr()         {}
+func (e *Literal) expr()           {}
+func (e *Grouping) expr()          {}
+func (e *InterpolationExpr) expr() {}
+func (e *Variable) expr()          {}
+func (e *Assign) expr()            {}
+func (e *Call) expr()              {}
+func (e *GetExpr) expr()           {}
+func (e *SetExpr) expr()           {}
+func (e *ListExpr) expr()          {}
+func (e *MapExpr) expr()           {}
+func (e *IndexExpr) expr()         {}
+func (e *SliceExpr) expr()         {}
+func (e *IndexSetExpr) expr()      {}
+func (e *ThisExpr) expr()          {}
+func (e *SuperExpr) expr()         {}
+func (e *FuncExpr) expr()          {}
+
+// Inspect the AST from node in depth-first order, like go/ast.Inspect:
+// f is called with node, and then with each of its children if it returns
+// true, followed by f(nil). The body of an arrow function is a synthesized
+// return statement, spanning from the => to the end of its expression.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+	for _, c := range children(node) {
+		Inspect(c, f)
+	}
+	f(nil)
+}
+
+// children of node in source order, leaving out those that are unset.
+func children(node Node) []Node {
+	var nodes []Node
+	add := func(ns ...Node) {
+		for _, n := range ns {
+			if n != nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	addStmts := func(stmts []Stmt) {
+		for _, s := range stmts {
+			add(s)
+		}
+	}
+	addExprs := func(exprs []Expr) {
+		for _, e := range exprs {
+			add(e)
+		}
+	}
+
+	switch n := node.(type) {
+	case *PrintStmt:
+		add(n.expr)
+	case *ExprStmt:
+		add(n.expr)
+	case *FuncStmt:
+		addStmts(n.body)
+	case *VarStmt:
+		add(n.init)
+	case *BlockStmt:
+		addStmts(n.statements)
+	case *IfStmt:
+		add(n.cond, n.thenBranch, n.elseBranch)
+	case *WhileStmt:
+		add(n.cond, n.body, n.incr)
+	case *ReturnStmt:
+		add(n.value)
+	case *ThrowStmt:
+		add(n.value)
+	case *TryStmt:
+		// Not add(n.catch), a nil *BlockStmt is not a nil Node.
+		for _, b := range []*BlockStmt{n.body, n.catch, n.finally} {
+			if b != nil {
+				add(b)
+			}
+		}
+	case *ClassStmt:
+		if n.super != nil {
+			add(n.super)
+		}
+		addStmts(n.methods)
+
+	case *BinaryExpr:
+		add(n.left, n.right)
+	case *LogicalExpr:
+		add(n.left, n.right)
+	case *UnaryExpr:
+		add(n.right)
+	case *Grouping:
+		add(n.group)
+	case *InterpolationExpr:
+		addExprs(n.parts)
+	case *Assign:
+		add(n.val)
+	case *Call:
+		add(n.callee)
+		addExprs(n.args)
+	case *GetExpr:
+		add(n.object)
+	case *SetExpr:
+		add(n.object, n.value)
+	case *ListExpr:
+		addExprs(n.elems)
+	case *MapExpr:
+		for j := range n.keys {
+			add(n.keys[j], n.values[j])
+		}
+	case *IndexExpr:
+		add(n.object, n.index)
+	case *SliceExpr:
+		add(n.object, n.lo, n.hi)
+	case *IndexSetExpr:
+		add(n.object, n.index, n.value)
+	case *FuncExpr:
+		add(n.decl)
+	}
+	return nodes
+}
 
 // PrintAST representation of Expr node.
 func PrintAST(nodes ...Node) string {
@@ -216,12 +539,21 @@ func printVisitor(node Node) any {
 		r := printVisitor(v.right)
 		return parenthesize(v.op.Literal, r)
 	case *Literal:
+		if s, ok := v.val.(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
 		return fmt.Sprintf("%v", v.val) // TODO: Parenthesis?
 	case *Variable:
 		return v.name.Literal
 	case *Grouping:
 		g := printVisitor(v.group)
 		return parenthesize("group", g)
+	case *InterpolationExpr:
+		vs := []any{"interp"}
+		for _, e := range v.parts {
+			vs = append(vs, printVisitor(e))
+		}
+		return parenthesize(vs...)
 	case *Assign:
 		g := printVisitor(v.val)
 		return parenthesize("assign", v.name.Literal, g)
@@ -251,12 +583,100 @@ func printVisitor(node Node) any {
 			vs = append(vs, "else", printVisitor(v.elseBranch))
 		}
 		return parenthesize(vs...)
+	case *WhileStmt:
+		if v.incr != nil {
+			return parenthesize("while", printVisitor(v.cond), printVisitor(v.body), printVisitor(v.incr))
+		}
+		return parenthesize("while", printVisitor(v.cond), printVisitor(v.body))
+	case *BreakStmt:
+		return parenthesize("break")
+	case *ContinueStmt:
+		return parenthesize("continue")
+	case *FuncStmt:
+		params := []any{}
+		for _, p := range v.params {
+			params = append(params, p.Literal)
+		}
+		vs := []any{"fun", v.name.Literal, parenthesize(params...)}
+		for _, s := range v.body {
+			vs = append(vs, printVisitor(s))
+		}
+		return parenthesize(vs...)
+	case *ReturnStmt:
+		if v.value == nil {
+			return parenthesize("return")
+		}
+		return parenthesize("return", printVisitor(v.value))
+	case *ThrowStmt:
+		return parenthesize("throw", printVisitor(v.value))
+	case *TryStmt:
+		vs := []any{"try", printVisitor(v.body)}
+		if v.catch != nil {
+			vs = append(vs, parenthesize("catch", v.name.Literal, printVisitor(v.catch)))
+		}
+		if v.finally != nil {
+			vs = append(vs, parenthesize("finally", printVisitor(v.finally)))
+		}
+		return parenthesize(vs...)
+	case *ClassStmt:
+		vs := []any{"class", v.name.Literal}
+		if v.super != nil {
+			vs = append(vs, "<", v.super.name.Literal)
+		}
+		for _, m := range v.methods {
+			vs = append(vs, printVisitor(m))
+		}
+		return parenthesize(vs...)
 	case *Call:
 		vs := []any{"call", printVisitor(v.callee)}
 		for _, s := range v.args {
 			vs = append(vs, printVisitor(s))
 		}
 		return parenthesize(vs...)
+	case *GetExpr:
+		return parenthesize("get", printVisitor(v.object), v.name.Literal)
+	case *SetExpr:
+		return parenthesize("set", printVisitor(v.object), v.name.Literal, printVisitor(v.value))
+	case *ListExpr:
+		vs := []any{"list"}
+		for _, e := range v.elems {
+			vs = append(vs, printVisitor(e))
+		}
+		return parenthesize(vs...)
+	case *MapExpr:
+		vs := []any{"map"}
+		for j := range v.keys {
+			vs = append(vs, printVisitor(v.keys[j]), printVisitor(v.values[j]))
+		}
+		return parenthesize(vs...)
+	case *IndexExpr:
+		return parenthesize("index", printVisitor(v.object), printVisitor(v.index))
+	case *SliceExpr:
+		// Missing bounds show as _.
+		lo, hi := "_", "_"
+		if v.lo != nil {
+			lo = printVisitor(v.lo).(string)
+		}
+		if v.hi != nil {
+			hi = printVisitor(v.hi).(string)
+		}
+		return parenthesize("slice", printVisitor(v.object), lo, hi)
+	case *IndexSetExpr:
+		return parenthesize("set-index", printVisitor(v.object), printVisitor(v.index), printVisitor(v.value))
+	case *ThisExpr:
+		return "this"
+	case *SuperExpr:
+		return parenthesize("super", v.method.Literal)
+	case *FuncExpr:
+		params := []any{}
+		for _, p := range v.decl.params {
+			params = append(params, p.Literal)
+		}
+		vs := []any{"fun", parenthesize(params...)}
+		for _, s := range v.decl.body {
+			vs = append(vs, printVisitor(s))
+		}
+		return parenthesize(vs...)
 	default:
 		panic(fmt.Sprintf("unknown as node: %T :: %#v", node, node))
 	}
@@ -1,8 +1,10 @@
 package glox_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/vikblom/glox"
 )
 
@@ -27,6 +29,19 @@ func TestPrinter(t *testing.T) {
 		{src: `if (a or b and c) 1;`, want: `(if (or a (and b c)) then (expr 1))`},
 
 		{src: `sum(1,2,3);`, want: `(expr (call sum 1 2 3))`},
+		{src: `while (a) a = a - 1;`, want: `(while a (expr (assign a (- a 1))))`},
+		{src: `fun f(a, b) { return a; }`, want: `(fun f (a b) (block (return a)))`},
+		{src: `fun f() { return; }`, want: `(fun f () (block (return)))`},
+		{src: `class A < B { m() { super.m(); } }`, want: `(class A < B (fun m () (block (expr (call (super m))))))`},
+		{src: `this.a = b.c;`, want: `(expr (set this a (get b c)))`},
+		{src: `for (;;i = i + 1) { break; continue; }`, want: `(while true (block (break) (continue)) (assign i (+ i 1)))`},
+		{src: `"a ${b} c ${d + 1}";`, want: `(expr (interp "a " b " c " (+ d 1) ""))`},
+		{src: `f(fun (a) { return a; });`, want: `(expr (call f (fun (a) (block (return a)))))`},
+		{src: `(a, b) => (a) + b;`, want: `(expr (fun (a b) (return (+ (group a) b))))`},
+		{src: `[1, [a], []];`, want: `(expr (list 1 (list a) (list)))`},
+		{src: `print {"a": 1, b: c, (d): {}};`, want: `(print (map "a" 1 "b" c (group d) (map)))`},
+		{src: `try { throw a; } catch (e) { b; } finally { c; }`, want: `(try (block (throw a)) (catch e (block (expr b))) (finally (block (expr c))))`},
+		{src: `xs[i][0] = xs[1:][:-1];`, want: `(expr (set-index (index xs i) 0 (slice (slice xs 1 _) _ (- 1))))`},
 	}
 
 	for _, tt := range tests {
@@ -47,3 +62,129 @@ func TestPrinter(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		src, stmt, expr string
+	}{
+		{src: "  1 + 2 ;", stmt: "1 + 2 ;", expr: "1 + 2"},
+		{src: "print (a or b);", stmt: "print (a or b);", expr: "(a or b)"},
+		{src: "var a = -b.c;\n", stmt: "var a = -b.c;", expr: "-b.c"},
+		{src: "a.b = f(1, 2);", stmt: "a.b = f(1, 2);", expr: "a.b = f(1, 2)"},
+		{src: "a = \"x\ny\";", stmt: "a = \"x\ny\";", expr: "a = \"x\ny\""},
+		{src: "{ a; } b;", stmt: "{ a; }"},
+		{src: "if (a) b; else { c; }", stmt: "if (a) b; else { c; }"},
+		{src: "while (a) { b; } c;", stmt: "while (a) { b; }"},
+		{src: "for (var i = 0; i < 1; i = i + 1) a;", stmt: "for (var i = 0; i < 1; i = i + 1) a;"},
+		{src: "fun f(a) {\n  return a;\n}", stmt: "fun f(a) {\n  return a;\n}"},
+		{src: "class A < B { m() { super.m(); } }", stmt: "class A < B { m() { super.m(); } }"},
+		{src: `print "a ${b}!";`, stmt: `print "a ${b}!";`, expr: `"a ${b}!"`},
+		{src: "fun () { a; }();", stmt: "fun () { a; }();", expr: "fun () { a; }()"},
+		{src: "f = (a) => a + 1;", stmt: "f = (a) => a + 1;", expr: "f = (a) => a + 1"},
+		{src: "print [1, 2];", stmt: "print [1, 2];", expr: "[1, 2]"},
+		{src: "m = {a: 1};", stmt: "m = {a: 1};", expr: "m = {a: 1}"},
+		{src: "try { a; } catch (e) { b; } c;", stmt: "try { a; } catch (e) { b; }"},
+		{src: "try { a; } finally { b; } c;", stmt: "try { a; } finally { b; }"},
+		{src: "throw Error(\"x\"); c;", stmt: "throw Error(\"x\");"},
+		{src: "xs[0] = ys[1:2];", stmt: "xs[0] = ys[1:2];", expr: "xs[0] = ys[1:2]"},
+	}
+
+	for _, tt := range tests {
+		toks, err := glox.ScanString(tt.src)
+		if err != nil {
+			t.Fatalf("scan string: %s", err)
+		}
+		stmts, err := glox.NewParser(toks).Parse()
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+
+		span := func(n glox.Node) string { return tt.src[n.Pos().Offset:n.End().Offset] }
+		if got := span(stmts[0]); got != tt.stmt {
+			t.Errorf("statement of %q spans %q but want %q", tt.src, got, tt.stmt)
+		}
+		if tt.expr == "" {
+			continue
+		}
+		if got := span(stmts[0].Stmt()); got != tt.expr {
+			t.Errorf("expression of %q spans %q but want %q", tt.src, got, tt.expr)
+		}
+	}
+}
+
+func TestNodeEndPosition(t *testing.T) {
+	src := "print \"a\nbc\";"
+	toks, err := glox.ScanFile("test.lox", []byte(src))
+	if err != nil {
+		t.Fatalf("scan string: %s", err)
+	}
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	if got, want := stmts[0].Stmt().End().String(), "test.lox:2:4"; got != want {
+		t.Errorf("string ends at %s but want %s", got, want)
+	}
+	if got, want := stmts[0].End().String(), "test.lox:2:5"; got != want {
+		t.Errorf("print ends at %s but want %s", got, want)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	src := `class A < B {
+  m(a) {
+    if (a) return (b) => b[1:] + a;
+    try { throw {k: [a]}; } finally { print "${a}"; }
+  }
+}`
+	toks, err := glox.ScanString(src)
+	if err != nil {
+		t.Fatalf("scan string: %s", err)
+	}
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	var got []string
+	glox.Inspect(stmts[0], func(n glox.Node) bool {
+		if n != nil {
+			got = append(got, src[n.Pos().Offset:n.End().Offset])
+		}
+		return true
+	})
+	want := []string{
+		src,
+		"B",
+		src[strings.Index(src, "m(a)") : len(src)-2],
+		src[strings.Index(src, "{\n    if") : len(src)-2],
+		"if (a) return (b) => b[1:] + a;",
+		"a",
+		"return (b) => b[1:] + a;",
+		"(b) => b[1:] + a",
+		"(b) => b[1:] + a",
+		"=> b[1:] + a",
+		"b[1:] + a",
+		"b[1:]",
+		"b",
+		"1",
+		"a",
+		`try { throw {k: [a]}; } finally { print "${a}"; }`,
+		"{ throw {k: [a]}; }",
+		"throw {k: [a]};",
+		"{k: [a]}",
+		"k",
+		"[a]",
+		"a",
+		`{ print "${a}"; }`,
+		`print "${a}";`,
+		`"${a}"`,
+		`"${`,
+		"a",
+		`}"`,
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("spans diff (-want, +got):\n%s", d)
+	}
+}
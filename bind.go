@@ -0,0 +1,463 @@
+package glox
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// RegisterStruct binds the struct type ptr points to as a global class
+// called name. Calling the class from Lox makes a new zero value.
+//
+// Go values of the type are instances of the class in Lox, which get and
+// set exported fields and call methods of the Go value through reflection.
+// A field tagged `lox:"name"` is called name in Lox, and `lox:"-"` hides it.
+// Values are converted between Lox and Go as they are passed through:
+// numbers, strings and bools to their Go kinds, lists to slices and arrays,
+// and maps to maps. Slices and maps are copied, but structs are not.
+func (i *Interpreter) RegisterStruct(name string, ptr any) error {
+	t := reflect.TypeOf(ptr)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("glox: RegisterStruct of %T, want a pointer to a struct", ptr)
+	}
+	c := newGoClass(i, name, t.Elem())
+	i.goClasses[t.Elem()] = c
+	i.global.define(name, c)
+	return nil
+}
+
+// goClass is a Go struct type bound as a Lox class.
+type goClass struct {
+	interp *Interpreter
+	name   string
+	typ    reflect.Type
+	// fields by Lox name, as indexes for reflect.Value.FieldByIndex.
+	fields map[string][]int
+}
+
+func newGoClass(i *Interpreter, name string, t reflect.Type) *goClass {
+	c := &goClass{interp: i, name: name, typ: t, fields: map[string][]int{}}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("lox"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		c.fields[name] = f.Index
+	}
+	return c
+}
+
+// goClassOf t, the registered one or else one named like the Go type.
+func (i *Interpreter) goClassOf(t reflect.Type) *goClass {
+	if c, ok := i.goClasses[t]; ok {
+		return c
+	}
+	name := t.Name()
+	if name == "" {
+		name = t.String()
+	}
+	c := newGoClass(i, name, t)
+	i.goClasses[t] = c
+	return c
+}
+
+func (c *goClass) arity() int { return 0 }
+
+// call constructs a new zero value.
+func (c *goClass) call(_ *Interpreter, _ []any) any {
+	return &goInstance{class: c, ptr: reflect.New(c.typ)}
+}
+
+func (c *goClass) String() string {
+	return fmt.Sprintf("<class %s>", c.name)
+}
+
+// goInstance is a pointer to a Go struct, proxied in Lox.
+type goInstance struct {
+	class *goClass
+	ptr   reflect.Value
+}
+
+// goKey of a goInstance in a map, the same for proxies of the same Go value
+// like isEqual says.
+type goKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+func (g *goInstance) key() goKey {
+	return goKey{typ: g.ptr.Type(), ptr: g.ptr.Pointer()}
+}
+
+func (g *goInstance) get(name Token) any {
+	if index, ok := g.class.fields[name.Literal]; ok {
+		f, err := g.ptr.Elem().FieldByIndexErr(index)
+		if err != nil {
+			runtimeErrf(name, CodeConversion, "Can't get %s.%s: %s.", g.class.name, name.Literal, err)
+		}
+		v, err := g.class.interp.toLox(f)
+		if err != nil {
+			runtimeErrf(name, CodeConversion, "Can't get %s.%s: %s.", g.class.name, name.Literal, err)
+		}
+		return v
+	}
+
+	if m := g.ptr.MethodByName(name.Literal); m.IsValid() {
+		return &goMethod{class: g.class.name, name: name.Literal, fn: m}
+	}
+
+	runtimeErrf(name, CodeUndefinedProperty, "Undefined property %q", name.Literal)
+	return nil
+}
+
+func (g *goInstance) set(name Token, v any) {
+	index, ok := g.class.fields[name.Literal]
+	if !ok {
+		runtimeErrf(name, CodeUndefinedProperty, "Undefined field %q of Go struct %s.", name.Literal, g.class.name)
+	}
+	f, err := g.ptr.Elem().FieldByIndexErr(index)
+	if err != nil {
+		runtimeErrf(name, CodeConversion, "Can't set %s.%s: %s.", g.class.name, name.Literal, err)
+	}
+	gv, err := toGo(v, f.Type())
+	if err != nil {
+		runtimeErrf(name, CodeConversion, "Can't set %s.%s: %s.", g.class.name, name.Literal, err)
+	}
+	f.Set(gv)
+}
+
+// String like the Go value if it is a fmt.Stringer.
+func (g *goInstance) String() string {
+	if s, ok := g.ptr.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("<instance %s>", g.class.name)
+}
+
+// goMethod is a method of a Go value, bound to it.
+type goMethod struct {
+	class, name string
+	fn          reflect.Value
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func (m *goMethod) arity() int {
+	if m.fn.Type().IsVariadic() {
+		return Variadic
+	}
+	return m.fn.Type().NumIn()
+}
+
+// call m, where a last error result is a runtime error if not nil.
+// Other results are returned as is, or in a list if there are many.
+func (m *goMethod) call(i *Interpreter, args []any) any {
+	t := m.fn.Type()
+	site := i.calls[len(i.calls)-1].site
+	if t.IsVariadic() && len(args) < t.NumIn()-1 {
+		runtimeErrf(site, CodeArity, "Expected at least %d arguments but got %d", t.NumIn()-1, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for j, a := range args {
+		var param reflect.Type
+		if t.IsVariadic() && j >= t.NumIn()-1 {
+			param = t.In(t.NumIn() - 1).Elem()
+		} else {
+			param = t.In(j)
+		}
+		v, err := toGo(a, param)
+		if err != nil {
+			runtimeErrf(site, CodeConversion, "Can't call %s.%s: argument %d: %s.", m.class, m.name, j+1, err)
+		}
+		in[j] = v
+	}
+
+	out := m.fn.Call(in)
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			i.nativeError(err)
+		}
+		out = out[:n-1]
+	}
+
+	results := make([]any, len(out))
+	for j, o := range out {
+		v, err := i.toLox(o)
+		if err != nil {
+			runtimeErrf(site, CodeConversion, "Can't return from %s.%s: %s.", m.class, m.name, err)
+		}
+		results[j] = v
+	}
+	switch len(results) {
+	case 0:
+		return nil
+	case 1:
+		return results[0]
+	}
+	return &LoxList{elems: results}
+}
+
+func (m *goMethod) String() string {
+	return fmt.Sprintf("<native fn %s.%s>", m.class, m.name)
+}
+
+// fromGo converts v like toLox, failing with a CodeConversion error
+// prefixed by what was converting, for Go values without a Lox value.
+func (i *Interpreter) fromGo(v Value, what string) (Value, error) {
+	lv, err := i.toLox(reflect.ValueOf(v))
+	if err != nil {
+		return nil, &RuntimeError{Code: CodeConversion, Msg: fmt.Sprintf("%s: %s.", what, err)}
+	}
+	return lv, nil
+}
+
+// toLox converts Go value v. Numbers become int64 or float64, slices and
+// arrays lists, maps maps and structs instances proxying them.
+// Lox values are left as they are.
+func (i *Interpreter) toLox(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case bool, int64, float64, string, callable, object:
+			return x, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows a Lox integer", v.Uint())
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return i.toLox(v.Elem())
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return &goInstance{class: i.goClassOf(v.Type().Elem()), ptr: v}, nil
+		}
+		return i.toLox(v.Elem())
+
+	case reflect.Struct:
+		if v.CanAddr() {
+			return &goInstance{class: i.goClassOf(v.Type()), ptr: v.Addr()}, nil
+		}
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return &goInstance{class: i.goClassOf(v.Type()), ptr: ptr}, nil
+
+	case reflect.Slice, reflect.Array:
+		elems := make([]any, v.Len())
+		for j := range elems {
+			e, err := i.toLox(v.Index(j))
+			if err != nil {
+				return nil, err
+			}
+			elems[j] = e
+		}
+		return &LoxList{elems: elems}, nil
+
+	case reflect.Map:
+		// In order, since Go maps are not.
+		keys := v.MapKeys()
+		sort.Slice(keys, func(a, b int) bool { return lessKey(keys[a], keys[b]) })
+		m := newMap()
+		for _, k := range keys {
+			lk, err := i.toLox(k)
+			if err != nil {
+				return nil, err
+			}
+			lv, err := i.toLox(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m.set(lk, lv)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("Go %s has no Lox value", v.Type())
+}
+
+// lessKey orders map keys a and b of the same type.
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// toGo converts Lox value v to Go type t.
+func toGo(v any, t reflect.Type) (reflect.Value, error) {
+	fail := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("%s %s is not a Go %s", typeName(v), inspect(v, map[any]bool{}), t)
+	}
+
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+		return fail()
+	}
+	if g, ok := v.(*goInstance); ok {
+		switch {
+		case g.ptr.Type().AssignableTo(t):
+			return g.ptr, nil
+		case g.ptr.Type().Elem().AssignableTo(t):
+			return g.ptr.Elem(), nil
+		}
+		return fail()
+	}
+
+	rv := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fail()
+		}
+		rv.SetBool(b)
+		return rv, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt(v)
+		if !ok {
+			return fail()
+		}
+		if rv.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("%d overflows Go %s", n, t)
+		}
+		rv.SetInt(n)
+		return rv, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toInt(v)
+		if !ok {
+			return fail()
+		}
+		if n < 0 || rv.OverflowUint(uint64(n)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows Go %s", n, t)
+		}
+		rv.SetUint(uint64(n))
+		return rv, nil
+
+	case reflect.Float32, reflect.Float64:
+		if !isNumber(v) {
+			return fail()
+		}
+		rv.SetFloat(toFloat(v))
+		return rv, nil
+
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return fail()
+		}
+		rv.SetString(s)
+		return rv, nil
+
+	case reflect.Slice, reflect.Array:
+		l, ok := v.(*LoxList)
+		if !ok {
+			return fail()
+		}
+		if t.Kind() == reflect.Slice {
+			rv = reflect.MakeSlice(t, len(l.elems), len(l.elems))
+		} else if t.Len() != len(l.elems) {
+			return reflect.Value{}, fmt.Errorf("list of length %d is not a Go %s", len(l.elems), t)
+		}
+		for j, e := range l.elems {
+			ge, err := toGo(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			rv.Index(j).Set(ge)
+		}
+		return rv, nil
+
+	case reflect.Map:
+		m, ok := v.(*LoxMap)
+		if !ok {
+			return fail()
+		}
+		rv = reflect.MakeMapWithSize(t, len(m.keys))
+		for j := range m.keys {
+			gk, err := toGo(m.keys[j], t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			gv, err := toGo(m.values[j], t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			rv.SetMapIndex(gk, gv)
+		}
+		return rv, nil
+
+	case reflect.Interface:
+		// Lists and maps become []any and map[any]any,
+		// or map[string]any if all keys are strings.
+		switch v := v.(type) {
+		case *LoxList:
+			return toGo(v, reflect.TypeOf([]any{}))
+		case *LoxMap:
+			mt := reflect.TypeOf(map[string]any{})
+			for _, k := range v.keys {
+				if _, ok := k.(string); !ok {
+					mt = reflect.TypeOf(map[any]any{})
+					break
+				}
+			}
+			return toGo(v, mt)
+		}
+		if gv := reflect.ValueOf(v); gv.Type().AssignableTo(t) {
+			return gv, nil
+		}
+	}
+	return fail()
+}
+
+// toInt v if it is an integer, or a float without fraction.
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case float64:
+		if n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,206 @@
+package glox_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vikblom/glox"
+)
+
+type point struct {
+	X, Y float64
+}
+
+type shape struct {
+	Name   string
+	Origin point
+	Tags   []string
+	Attrs  map[string]int
+	Count  uint8 `lox:"count"`
+	secret int
+	Hidden int `lox:"-"`
+}
+
+func (s *shape) Move(dx, dy float64) {
+	s.Origin.X += dx
+	s.Origin.Y += dy
+}
+
+func (s *shape) Tag(tags ...string) int {
+	s.Tags = append(s.Tags, tags...)
+	return len(s.Tags)
+}
+
+func (s *shape) Check() (bool, error) {
+	if s.Name == "" {
+		return false, errors.New("shape has no name")
+	}
+	return true, nil
+}
+
+func (s *shape) Corners() (int, string) {
+	return 4, "square"
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("(%g, %g)", p.X, p.Y)
+}
+
+func TestRegisterStruct(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	vm := glox.NewVM(buf)
+	if err := vm.RegisterStruct("Shape", (*shape)(nil)); err != nil {
+		t.Fatalf("register: %s", err)
+	}
+
+	// A Go value set from Go is shared with Lox.
+	sq := &shape{Name: "square", Attrs: map[string]int{"b": 2, "a": 1}}
+	vm.SetGlobal("sq", sq)
+
+	_, err := vm.Eval(`
+print sq;
+print sq.Name;
+sq.Name = "box";
+sq.Move(1, 2.5);
+print sq.Origin;
+print sq.Origin.X + sq.Origin.Y;
+sq.Origin.Y = 0;
+print sq.Tag("a", "b");
+print sq.Tags;
+print sq.Attrs;
+sq.Attrs = {c: 3};
+sq.count = 255;
+print sq.Corners();
+print sq.Check();
+
+// New zero values from Lox.
+var s = Shape();
+s.Tags = ["x"];
+print s;
+print s.Tags;
+print s.Origin == s.Origin;
+
+// Map keys agree with ==, also for proxies of the same Go value.
+var m = {};
+m[s.Origin] = 1;
+m[s.Origin] = 2;
+print m.has(s.Origin);
+print m.len();
+print m[s.Origin];
+print m.has(sq.Origin);
+try {
+  s.Check();
+} catch (e) {
+  print e.message;
+}
+`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+
+	want := `<instance Shape>
+square
+(1, 2.5)
+3.5
+2
+["a", "b"]
+{"a": 1, "b": 2}
+[4, "square"]
+true
+<instance Shape>
+["x"]
+true
+true
+1
+2
+false
+shape has no name
+`
+	if d := cmp.Diff(want, buf.String()); d != "" {
+		t.Errorf("printed diff (-want, +got):\n%s", d)
+	}
+
+	wantShape := &shape{
+		Name:   "box",
+		Origin: point{X: 1, Y: 0},
+		Tags:   []string{"a", "b"},
+		Attrs:  map[string]int{"c": 3},
+		Count:  255,
+	}
+	if d := cmp.Diff(wantShape, sq, cmp.AllowUnexported(shape{})); d != "" {
+		t.Errorf("Go value diff (-want, +got):\n%s", d)
+	}
+}
+
+func TestRegisterStructErrors(t *testing.T) {
+	vm := glox.NewVM(io.Discard)
+	if err := vm.RegisterStruct("Point", point{}); err == nil {
+		t.Errorf("expected error registering a struct value")
+	}
+	if err := vm.RegisterStruct("Shape", &shape{}); err != nil {
+		t.Fatalf("register: %s", err)
+	}
+
+	tests := []struct {
+		src  string
+		code glox.ErrorCode
+		want string
+	}{
+		{src: `Shape().Name = 1;`, code: glox.CodeConversion, want: "1:9: Can't set Shape.Name: number 1 is not a Go string."},
+		{src: `Shape().count = 256;`, code: glox.CodeConversion, want: "1:9: Can't set Shape.count: 256 overflows Go uint8."},
+		{src: `Shape().count = -1;`, code: glox.CodeConversion, want: "1:9: Can't set Shape.count: -1 overflows Go uint8."},
+		{src: `Shape().Tags = [1];`, code: glox.CodeConversion, want: "1:9: Can't set Shape.Tags: number 1 is not a Go string."},
+		{src: `Shape().Origin = nil;`, code: glox.CodeConversion, want: "1:9: Can't set Shape.Origin: nil <nil> is not a Go glox_test.point."},
+		{src: `Shape().Move("a", 1);`, code: glox.CodeConversion, want: `1:20: Can't call Shape.Move: argument 1: string "a" is not a Go float64.`},
+		{src: `Shape().Move(1);`, code: glox.CodeArity, want: "1:15: Expected 2 arguments but got 1"},
+		{src: `Shape().secret;`, code: glox.CodeUndefinedProperty, want: `1:9: Undefined property "secret"`},
+		{src: `Shape().Hidden = 1;`, code: glox.CodeUndefinedProperty, want: `1:9: Undefined field "Hidden" of Go struct Shape.`},
+	}
+	for _, tt := range tests {
+		_, err := vm.Eval(tt.src)
+		var rerr *glox.RuntimeError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("Eval(%q) = %v but want a runtime error", tt.src, err)
+		}
+		if rerr.Code != tt.code || rerr.Error() != tt.want {
+			t.Errorf("Eval(%q) = %s %q but want %s %q", tt.src, rerr.Code, rerr, tt.code, tt.want)
+		}
+	}
+
+	// Go values without a Lox value can't be passed into Lox.
+	var rerr *glox.RuntimeError
+	err := vm.SetGlobal("ch", make(chan int))
+	if !errors.As(err, &rerr) || rerr.Code != glox.CodeConversion || rerr.Error() != "Can't set ch: Go chan int has no Lox value." {
+		t.Errorf("SetGlobal(ch) = %v but want a conversion error", err)
+	}
+	if _, ok := vm.GetGlobal("ch"); ok {
+		t.Errorf("SetGlobal(ch) defined ch after failing")
+	}
+
+	type withChan struct{ C chan int }
+	if err := vm.SetGlobal("w", &withChan{}); err != nil {
+		t.Fatalf("SetGlobal(w): %s", err)
+	}
+	_, err = vm.Eval(`w.C;`)
+	if !errors.As(err, &rerr) || rerr.Error() != "1:3: Can't get withChan.C: Go chan int has no Lox value." {
+		t.Errorf("Eval(w.C) = %v but want a conversion error", err)
+	}
+
+	vm.RegisterFunc("callback", 0, func(args []glox.Value) (glox.Value, error) {
+		return func() {}, nil
+	})
+	_, err = vm.Eval(`callback() == callback();`)
+	if !errors.As(err, &rerr) || rerr.Code != glox.CodeConversion || rerr.Error() != "1:10: Can't return from callback: Go func() has no Lox value." {
+		t.Errorf("Eval(callback()) = %v but want a conversion error", err)
+	}
+
+	shape, _ := vm.GetGlobal("Shape")
+	_, err = vm.Call(shape, func() {})
+	if !errors.As(err, &rerr) || rerr.Code != glox.CodeConversion || rerr.Error() != "Can't call with argument 1: Go func() has no Lox value." {
+		t.Errorf("Call(Shape, func) = %v but want a conversion error", err)
+	}
+}
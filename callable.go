@@ -2,48 +2,99 @@ package glox
 
 import (
 	"fmt"
-	"time"
+	"reflect"
 )
 
-type builtinClock struct{}
+type callable interface {
+	call(i *Interpreter, args []any) any
+	// arity is the number of arguments to call with, or Variadic.
+	arity() int
+}
+
+// Func is a Go function callable from Lox, see RegisterFunc.
+type Func func(args []Value) (Value, error)
 
-func (b *builtinClock) arity() int { return 0 }
+// Variadic arity of a Func taking any number of arguments.
+const Variadic = -1
 
-func (b *builtinClock) call(_ *Interpreter, _ []any) any {
-	return time.Now().Unix()
+// nativeFunc is a Func registered as a global.
+type nativeFunc struct {
+	name   string
+	params int
+	fn     Func
 }
 
-type callable interface {
-	call(i *Interpreter, args []any) any
-	arity() int
+func (f *nativeFunc) arity() int { return f.params }
+
+func (f *nativeFunc) call(i *Interpreter, args []any) any {
+	v, err := f.fn(args)
+	if err != nil {
+		i.nativeError(err)
+	}
+	lv, err := i.toLox(reflect.ValueOf(v))
+	if err != nil {
+		site := i.calls[len(i.calls)-1].site
+		runtimeErrf(site, CodeConversion, "Can't return from %s: %s.", f.name, err)
+	}
+	return lv
+}
+
+// nativeError panics with err from Go as a runtime error where Lox called.
+func (i *Interpreter) nativeError(err error) {
+	site := i.calls[len(i.calls)-1].site
+	panic(&RuntimeError{
+		Pos:  site.Pos(),
+		End:  site.EndPos(),
+		Code: CodeNative,
+		Msg:  err.Error(),
+		err:  err,
+	})
+}
+
+func (f *nativeFunc) String() string {
+	return fmt.Sprintf("<native fn %s>", f.name)
+}
+
+// frameName of c for stack traces.
+// Calling a class runs its initializer.
+func frameName(c callable) (class, function string) {
+	switch c := c.(type) {
+	case *LoxFunction:
+		// The frame already says where an anonymous function is.
+		if c.decl.name.Literal == "" {
+			return c.class, "anonymous"
+		}
+		return c.class, c.decl.name.Literal
+	case *LoxClass:
+		if init := c.findMethod("init"); init != nil {
+			return init.class, "init"
+		}
+		return c.name, "init"
+	case *nativeFunc:
+		return "", c.name
+	case *goClass:
+		return c.name, "init"
+	case *goMethod:
+		return c.class, c.name
+	case *nativeMethod:
+		return c.class, c.name.Literal
+	}
+	return "", fmt.Sprintf("%v", c)
 }
 
 type LoxFunction struct {
 	decl          *FuncStmt
 	closure       *Env
 	isInitializer bool
+	// class name if this is a method.
+	class string
 }
 
 func (f *LoxFunction) arity() int {
 	return len(f.decl.params)
 }
 
-func (f *LoxFunction) call(i *Interpreter, args []any) (ret any) {
-	// Using panics to unwind the stack on return...
-	defer func() {
-		if r := recover(); r != nil {
-			if re, ok := r.(returnValue); ok {
-				// Constructors implicitly return "this".
-				if f.isInitializer {
-					ret = f.closure.get("this")
-					return
-				}
-				ret = re.any
-			} else {
-				panic(r)
-			}
-		}
-	}()
+func (f *LoxFunction) call(i *Interpreter, args []any) any {
 	// Each function captures the environment where it was _declared_.
 	// Closing over variables there.
 	env := f.closure.Fork()
@@ -51,23 +102,39 @@ func (f *LoxFunction) call(i *Interpreter, args []any) (ret any) {
 		env.define(param.Literal, args[i])
 	}
 
-	i.executeBlock(f.decl.body, env)
+	c := i.executeBlock(f.decl.body, env)
+	if c.kind == throwing {
+		// Calls are expressions, which panic instead.
+		panic(c.err)
+	}
 
+	// Constructors implicitly return "this".
 	if f.isInitializer {
-		return f.closure.get("this")
+		return f.closure.vars["this"]
 	}
-
-	return nil
+	return c.value
 }
 
 func (f *LoxFunction) String() string {
-	return fmt.Sprintf("<fn %s>", f.decl.name.Literal)
+	return fmt.Sprintf("<fn %s>", f.name())
+}
+
+// name of f, or where it was declared if anonymous, like "anonymous@a.lox:12".
+func (f *LoxFunction) name() string {
+	if f.decl.name.Literal != "" {
+		return f.decl.name.Literal
+	}
+	pos := f.decl.Pos()
+	if pos.File == "" {
+		return fmt.Sprintf("anonymous@%d", pos.Line)
+	}
+	return fmt.Sprintf("anonymous@%s:%d", pos.File, pos.Line)
 }
 
 func (f *LoxFunction) bind(inst *LoxInstance) *LoxFunction {
 	env := f.closure.Fork()
 	env.define("this", inst)
-	return &LoxFunction{closure: env, decl: f.decl, isInitializer: f.isInitializer}
+	return &LoxFunction{closure: env, decl: f.decl, isInitializer: f.isInitializer, class: f.class}
 }
 
 type LoxClass struct {
@@ -100,6 +167,16 @@ func (c *LoxClass) String() string {
 	return fmt.Sprintf("<class %s>", c.name)
 }
 
+// isSubclass if c is other, or inherits from it.
+func (c *LoxClass) isSubclass(other *LoxClass) bool {
+	for ; c != nil; c = c.super {
+		if c == other {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *LoxClass) findMethod(name string) *LoxFunction {
 	m, ok := c.methods[name]
 	if ok {
@@ -111,6 +188,12 @@ func (c *LoxClass) findMethod(name string) *LoxFunction {
 	return nil
 }
 
+// object has properties, like the fields of an instance
+// or the methods of a list.
+type object interface {
+	get(name Token) any
+}
+
 type LoxInstance struct {
 	class  *LoxClass
 	fields map[string]any
@@ -120,18 +203,18 @@ func (i *LoxInstance) set(name string, v any) {
 	i.fields[name] = v
 }
 
-func (i *LoxInstance) get(name string) any {
-	v, ok := i.fields[name]
+func (i *LoxInstance) get(name Token) any {
+	v, ok := i.fields[name.Literal]
 	if ok {
 		return v
 	}
 
-	m := i.class.findMethod(name)
+	m := i.class.findMethod(name.Literal)
 	if m != nil {
 		return m.bind(i)
 	}
 
-	runtimeErrf("Undefined property %q", name)
+	runtimeErrf(name, CodeUndefinedProperty, "Undefined property %q", name.Literal)
 	return nil
 }
 
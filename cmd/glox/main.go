@@ -1,36 +1,158 @@
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/vikblom/glox"
 )
 
-func runMain() error {
-	sc := bufio.NewScanner(os.Stdin)
+// Exit codes from sysexits.h, same as the book uses.
+const (
+	exitUsage   = 64 // Bad command line.
+	exitData    = 65 // Scanning, parsing or resolving failed.
+	exitNoInput = 66 // Script could not be read.
+	exitRuntime = 70 // Interpreting failed.
+)
+
+// exitError tells main which status code to exit with.
+type exitError struct {
+	code int
+	err  error
+	// file and src err points into, if any.
+	file string
+	src  []byte
+}
+
+func (e *exitError) Error() string { return glox.FormatError(e.file, e.src, e.err) }
+func (e *exitError) Unwrap() error { return e.err }
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  glox                  start a REPL
+  glox run script.lox   run a script
+  glox script.lox       same as run
+  glox run -            run a script from stdin, statement by statement
+  glox check script.lox report errors in a script without running it
+`)
+}
+
+// runFile at path, or stdin for "-", writing any output to stdout.
+func runFile(path string) error {
+	if path == "-" {
+		return runStream("<stdin>", os.Stdin)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return &exitError{code: exitNoInput, err: err}
+	}
+	return run(path, src)
+}
+
+// runStream from r, running each statement as soon as it is parsed,
+// so the whole program is never in memory.
+func runStream(file string, r io.Reader) error {
+	parser := glox.NewStreamParser(glox.NewReaderScanner(file, r))
+	interp := glox.NewInterpreter(os.Stdout)
 	for {
-		fmt.Printf("> ")
-		if !sc.Scan() {
-			break
+		stmt, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
 		}
-		lexer := glox.NewScanner(sc.Bytes())
-		for {
-			tok := lexer.Scan()
-			if tok.Kind == glox.EOF {
-				break
-			}
-			fmt.Printf("%v\n", tok)
+		if err != nil {
+			return &exitError{code: exitData, err: err}
+		}
+
+		_, err = interp.Eval([]glox.Stmt{stmt})
+		var re *glox.RuntimeError
+		if errors.As(err, &re) {
+			return &exitError{code: exitRuntime, err: err}
+		}
+		if err != nil {
+			return &exitError{code: exitData, err: err}
 		}
 	}
+}
+
+// run src from file as a complete program.
+func run(file string, src []byte) error {
+	toks, err := glox.ScanFile(file, src)
+	if err != nil {
+		return &exitError{code: exitData, err: err, file: file, src: src}
+	}
+
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		return &exitError{code: exitData, err: err, file: file, src: src}
+	}
+
+	err = glox.NewInterpreter(os.Stdout).Interpret(stmts)
+	var re *glox.RuntimeError
+	if errors.As(err, &re) {
+		return &exitError{code: exitRuntime, err: err, file: file, src: src}
+	}
+	if err != nil {
+		// Resolving finds static errors, before anything runs.
+		return &exitError{code: exitData, err: err, file: file, src: src}
+	}
+	return nil
+}
+
+// checkFile at path for static errors.
+func checkFile(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return &exitError{code: exitNoInput, err: err}
+	}
+
+	toks, err := glox.ScanFile(path, src)
+	if err != nil {
+		return &exitError{code: exitData, err: err, file: path, src: src}
+	}
+
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		return &exitError{code: exitData, err: err, file: path, src: src}
+	}
+
+	err = glox.NewResolver(nil).Resolve(stmts)
+	if err != nil {
+		return &exitError{code: exitData, err: err, file: path, src: src}
+	}
 	return nil
 }
 
+func runMain(args []string) error {
+	switch {
+	case len(args) == 0:
+		return runREPL()
+	case args[0] == "run" && len(args) == 2:
+		return runFile(args[1])
+	case args[0] == "check" && len(args) == 2:
+		return checkFile(args[1])
+	case args[0] != "run" && args[0] != "check" && len(args) == 1:
+		return runFile(args[0])
+	default:
+		usage()
+		return &exitError{code: exitUsage, err: errors.New("bad arguments")}
+	}
+}
+
 func main() {
-	err := runMain()
+	flag.Usage = usage
+	flag.Parse()
+
+	err := runMain(flag.Args())
 	if err != nil {
-		fmt.Printf("glox failed: %s", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "glox: %s\n", err)
+		code := 1
+		var ee *exitError
+		if errors.As(err, &ee) {
+			code = ee.code
+		}
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunMainExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ok.lox":      "var a = 1;\n",
+		"syntax.lox":  "var a = ;\n",
+		"resolve.lox": "return 1;\n",
+		"runtime.lox": "var a = nil;\na.b;\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Keep usage out of the test output.
+	flag.CommandLine.SetOutput(io.Discard)
+	defer flag.CommandLine.SetOutput(nil)
+
+	tests := []struct {
+		args []string
+		// code exited with, 0 for no error.
+		code int
+	}{
+		{args: []string{"ok.lox"}, code: 0},
+		{args: []string{"run", "ok.lox"}, code: 0},
+		{args: []string{"check", "ok.lox"}, code: 0},
+
+		{args: []string{"missing.lox"}, code: exitNoInput},
+		{args: []string{"check", "missing.lox"}, code: exitNoInput},
+
+		{args: []string{"syntax.lox"}, code: exitData},
+		{args: []string{"check", "syntax.lox"}, code: exitData},
+		{args: []string{"resolve.lox"}, code: exitData},
+		{args: []string{"check", "resolve.lox"}, code: exitData},
+
+		{args: []string{"runtime.lox"}, code: exitRuntime},
+		// Checking does not run anything.
+		{args: []string{"check", "runtime.lox"}, code: 0},
+
+		{args: []string{"run"}, code: exitUsage},
+		{args: []string{"check"}, code: exitUsage},
+		{args: []string{"ok.lox", "extra"}, code: exitUsage},
+		{args: []string{"run", "ok.lox", "extra"}, code: exitUsage},
+	}
+	for _, tt := range tests {
+		args := make([]string, len(tt.args))
+		for j, a := range tt.args {
+			args[j] = a
+			if filepath.Ext(a) == ".lox" {
+				args[j] = filepath.Join(dir, a)
+			}
+		}
+
+		err := runMain(args)
+		code := 0
+		if err != nil {
+			var ee *exitError
+			if !errors.As(err, &ee) {
+				t.Fatalf("runMain(%q) = %v but want an *exitError", tt.args, err)
+			}
+			code = ee.code
+		}
+		if code != tt.code {
+			t.Errorf("runMain(%q) exits with %d but want %d: %v", tt.args, code, tt.code, err)
+		}
+	}
+}
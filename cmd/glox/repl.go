@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/vikblom/glox"
+)
+
+// historyFile in the home directory, keeping REPL input between sessions.
+const historyFile = ".glox_history"
+
+const replHelp = `Enter statements to run them, expression statements print their value.
+Commands:
+  :help           show this help
+  :load FILE      run FILE in this session
+  :reset          forget all globals
+  :env            list globals
+  :ast SRC        print the syntax tree of SRC
+  :tokens SRC     print the tokens of SRC
+  :time SRC       run SRC and print how long it took
+`
+
+type repl struct {
+	interp *glox.Interpreter
+	out    io.Writer
+	// errOut gets errors, formatted to point into what was typed.
+	errOut io.Writer
+	// inputs read so far, numbering their file names.
+	inputs int
+}
+
+// runREPL reads statements from stdin and interprets them as they come.
+// Globals, functions and classes live on between inputs.
+func runREPL() error {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+
+	if path, err := historyPath(); err == nil {
+		if f, err := os.Open(path); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+		defer func() {
+			if f, err := os.Create(path); err == nil {
+				line.WriteHistory(f)
+				f.Close()
+			}
+		}()
+	}
+
+	r := &repl{interp: glox.NewInterpreter(os.Stdout), out: os.Stdout, errOut: os.Stderr}
+	return r.run(&promptReader{repl: r, line: line})
+}
+
+// run statements read from in until the user is done.
+func (r *repl) run(in *promptReader) error {
+	for {
+		// Scan what is typed as it is needed, so a statement can span lines.
+		in.reset()
+		file := r.nextFile()
+		parser := glox.NewStreamParser(glox.NewReaderScanner(file, in))
+		for {
+			stmt, err := parser.Next()
+			if in.eof {
+				fmt.Fprintln(r.out)
+				return nil
+			}
+			if errors.Is(err, liner.ErrPromptAborted) {
+				// Ctrl-C drops whatever was typed so far.
+				break
+			}
+			if errors.Is(err, io.EOF) {
+				// What was typed is used up, like after a comment.
+				break
+			}
+			if err == nil {
+				in.parsed = stmt.End().Offset
+				err = r.eval([]glox.Stmt{stmt})
+			}
+			if err != nil {
+				fmt.Fprintf(r.errOut, "%s\n", glox.FormatError(file, in.src, err))
+			}
+			if err != nil {
+				// Drop the rest of the input the error was in.
+				break
+			}
+			if in.done() {
+				// Start over, so errors in the next input count lines from 1.
+				break
+			}
+		}
+	}
+}
+
+// prompter reads a line typed after a prompt, like liner.State.
+type prompter interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(item string)
+}
+
+// promptReader reads lines typed at the prompt, and runs meta-commands.
+// It ends the input once what was typed parses as complete statements,
+// so they run without waiting for the parser to look past them, like
+// an if looking for an else.
+type promptReader struct {
+	repl *repl
+	line prompter
+	// eof if the user is done.
+	eof bool
+	// src typed so far, for errors to point into.
+	src []byte
+	// unread part of the last line.
+	unread []byte
+	// parsed is the offset in src just past the last statement parsed.
+	parsed int
+}
+
+// reset for reading from a new scanner.
+func (r *promptReader) reset() {
+	r.src = nil
+	r.unread = nil
+	r.parsed = 0
+}
+
+// pending if more than whitespace was typed after the last statement parsed,
+// so the next line continues a statement.
+func (r *promptReader) pending() bool {
+	return len(bytes.TrimSpace(r.src[r.parsed:])) > 0
+}
+
+// done if nothing but whitespace was typed after the last statement parsed.
+func (r *promptReader) done() bool {
+	return len(r.unread) == 0 && !r.pending()
+}
+
+func (r *promptReader) Read(p []byte) (int, error) {
+	if len(r.unread) == 0 && len(r.src) > 0 && !incomplete(parseErr(r.src)) {
+		return 0, io.EOF
+	}
+	for len(r.unread) == 0 {
+		more := r.pending()
+		prompt := "> "
+		if more {
+			prompt = "... "
+		}
+		input, err := r.line.Prompt(prompt)
+		if errors.Is(err, io.EOF) {
+			r.eof = true
+		}
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(input) != "" {
+			r.line.AppendHistory(input)
+		}
+
+		if !more {
+			if strings.TrimSpace(input) == "" {
+				continue
+			}
+			if strings.HasPrefix(strings.TrimSpace(input), ":") {
+				r.repl.command(input)
+				continue
+			}
+		}
+		r.unread = []byte(input + "\n")
+		r.src = append(r.src, r.unread...)
+	}
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+func historyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, historyFile), nil
+}
+
+// nextFile name for an input, so errors in code from earlier inputs
+// are not shown against a later one.
+func (r *repl) nextFile() string {
+	r.inputs++
+	return fmt.Sprintf("<repl:%d>", r.inputs)
+}
+
+// eval stmts and echo the value of a trailing expression statement.
+func (r *repl) eval(stmts []glox.Stmt) error {
+	v, err := r.interp.Eval(stmts)
+	if err != nil {
+		return err
+	}
+	if v != nil {
+		fmt.Fprintln(r.out, glox.Stringify(v))
+	}
+	return nil
+}
+
+// command runs a meta-command like ":load file.lox", reporting any errors.
+func (r *repl) command(input string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	arg = strings.TrimSpace(arg)
+
+	file, src, err := r.runCommand(name, arg)
+	if err != nil {
+		fmt.Fprintf(r.errOut, "%s\n", glox.FormatError(file, src, err))
+	}
+}
+
+// runCommand name with arg, returning the file and source any error
+// points into.
+func (r *repl) runCommand(name, arg string) (string, []byte, error) {
+	src := []byte(arg)
+	switch name {
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+
+	case ":load":
+		src, err := os.ReadFile(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		stmts, err := parse(arg, src)
+		if err != nil {
+			return arg, src, err
+		}
+		return arg, src, r.interp.Interpret(stmts)
+
+	case ":reset":
+		r.interp = glox.NewInterpreter(r.out)
+
+	case ":env":
+		globals := r.interp.Globals()
+		names := make([]string, 0, len(globals))
+		for name := range globals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s = %s\n", name, glox.Stringify(globals[name]))
+		}
+
+	case ":ast":
+		stmts, err := parseLenient("", arg)
+		if err != nil {
+			return "", src, err
+		}
+		fmt.Fprintf(r.out, "%s\n", glox.PrintAST(stmtNodes(stmts)...))
+
+	case ":tokens":
+		toks, err := glox.ScanString(arg)
+		if err != nil {
+			return "", src, err
+		}
+		for _, tok := range toks {
+			fmt.Fprintf(r.out, "%v\n", &tok)
+		}
+
+	case ":time":
+		file := r.nextFile()
+		stmts, err := parseLenient(file, arg)
+		if err != nil {
+			return file, src, err
+		}
+		start := time.Now()
+		err = r.eval(stmts)
+		fmt.Fprintf(r.out, "took %s\n", time.Since(start))
+		return file, src, err
+
+	default:
+		return "", nil, fmt.Errorf("unknown command %q, try :help", name)
+	}
+	return "", nil, nil
+}
+
+// parse src from file into statements.
+func parse(file string, src []byte) ([]glox.Stmt, error) {
+	toks, err := glox.ScanFile(file, src)
+	if err != nil {
+		return nil, err
+	}
+	return glox.NewParser(toks).Parse()
+}
+
+// parseErr of src, if it does not parse.
+func parseErr(src []byte) error {
+	_, err := parse("", src)
+	return err
+}
+
+// incomplete if err just means more input is needed.
+func incomplete(err error) bool {
+	var l glox.SyntaxErrors
+	if errors.As(err, &l) {
+		// Earlier errors will not go away with more input.
+		return errors.Is(l[0], io.ErrUnexpectedEOF)
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseLenient is like parse but forgives a missing trailing ';'.
+func parseLenient(file, src string) ([]glox.Stmt, error) {
+	stmts, err := parse(file, []byte(src))
+	if incomplete(err) {
+		if s, err := parse(file, []byte(src+";")); err == nil {
+			return s, nil
+		}
+	}
+	return stmts, err
+}
+
+// stmtNodes converts stmts for PrintAST.
+func stmtNodes(stmts []glox.Stmt) []glox.Node {
+	nodes := make([]glox.Node, len(stmts))
+	for i, s := range stmts {
+		nodes[i] = s
+	}
+	return nodes
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vikblom/glox"
+)
+
+// scripted types lines at the prompt, recording what was printed by then.
+type scripted struct {
+	lines []string
+	out   *bytes.Buffer
+	// seen output and prompt, each time a line was prompted for.
+	seen []string
+}
+
+func (s *scripted) Prompt(prompt string) (string, error) {
+	s.seen = append(s.seen, s.out.String()+prompt)
+	if len(s.lines) == 0 {
+		return "", io.EOF
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, nil
+}
+
+func (s *scripted) AppendHistory(string) {}
+
+func TestREPL(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		// seen at each prompt.
+		seen []string
+	}{
+		{
+			name:  "if runs without waiting for else",
+			lines: []string{"if (true) print 1;", "print 2;"},
+			seen:  []string{"> ", "1\n> ", "1\n2\n> "},
+		},
+		{
+			name:  "else on the same line",
+			lines: []string{"if (false) print 1; else print 2;"},
+			seen:  []string{"> ", "2\n> "},
+		},
+		{
+			name:  "statement spanning lines",
+			lines: []string{"fun f() {", "  return 3;", "}", "f();"},
+			seen:  []string{"> ", "... ", "... ", "> ", "3\n> "},
+		},
+		{
+			name:  "statement after a complete one on the same line",
+			lines: []string{"var a = 1; a", ";", "a;"},
+			seen:  []string{"> ", "... ", "1\n> ", "1\n1\n> "},
+		},
+		{
+			name:  "comment",
+			lines: []string{"// nothing", "1 + 1;"},
+			seen:  []string{"> ", "> ", "2\n> "},
+		},
+		{
+			name:  "runtime error drops the rest of the line",
+			lines: []string{"print 1; print nil.x; print 3;", "print 4;"},
+			seen:  []string{"> ", "1\n> ", "1\n4\n> "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.NewBuffer(nil)
+			r := &repl{interp: glox.NewInterpreter(out), out: out, errOut: io.Discard}
+			s := &scripted{lines: tt.lines, out: out}
+			if err := r.run(&promptReader{repl: r, line: s}); err != nil {
+				t.Fatalf("run: %s", err)
+			}
+			if d := cmp.Diff(tt.seen, s.seen); d != "" {
+				t.Errorf("seen at prompts diff (-want, +got):\n%s", d)
+			}
+		})
+	}
+}
+
+func TestREPLErrorsPointIntoTheirInput(t *testing.T) {
+	out, errOut := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	r := &repl{interp: glox.NewInterpreter(out), out: out, errOut: errOut}
+	s := &scripted{
+		lines: []string{
+			"fun f() { return nil.x; }",
+			`print "a long string goes here"; f();`,
+			"print nope;",
+		},
+		out: out,
+	}
+	if err := r.run(&promptReader{repl: r, line: s}); err != nil {
+		t.Fatalf("run: %s", err)
+	}
+
+	// f is not in the input calling it, so there is no line to point at.
+	want := "<repl:1>:1:22: Object nil does not have properties, must be instance.\n" +
+		"at f (<repl:1>:1)\n" +
+		"at <script> (<repl:2>:1)\n" +
+		"<repl:3>:1:7: Undefined variable \"nope\".\n" +
+		"\tprint nope;\n" +
+		"\t      ^^^^\n"
+	if d := cmp.Diff(want, errOut.String()); d != "" {
+		t.Errorf("errors diff (-want, +got):\n%s", d)
+	}
+}
+
+func TestREPLCommands(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"good.lox": "var loaded = \"yes\";\n",
+		"bad.lox":  "var x = 1;\nprint (x;\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		// out and errOut printed, with DIR for the directory of files
+		// and "took" lines without the time.
+		out, errOut string
+	}{
+		{
+			name:  "env after var",
+			lines: []string{"var a = 1;", ":env"},
+			out:   "Error = <class Error>\na = 1\nclock = <native fn clock>\n\n",
+		},
+		{
+			name:   "reset forgets globals",
+			lines:  []string{"var a = 1;", ":reset", ":env", "a;"},
+			out:    "Error = <class Error>\nclock = <native fn clock>\n\n",
+			errOut: "<repl:2>:1:1: Undefined variable \"a\".\n\ta;\n\t^\n",
+		},
+		{
+			name:  "ast without trailing semicolon",
+			lines: []string{":ast 1 + 2 * a"},
+			out:   "(expr (+ 1 (* 2 a)))\n\n",
+		},
+		{
+			name:  "time without trailing semicolon",
+			lines: []string{":time var b = 2", "b;"},
+			out:   "took\n2\n\n",
+		},
+		{
+			name:  "tokens",
+			lines: []string{":tokens a = 1"},
+			out:   "[1:1] IDENT: \"a\"\n[1:3] =: \"=\"\n[1:5] NUMBER: \"1\"\n[1:6] EOF: \"\"\n\n",
+		},
+		{
+			name:  "load",
+			lines: []string{":load DIR/good.lox", "loaded;"},
+			out:   "yes\n\n",
+		},
+		{
+			// Nothing in the file runs.
+			name:  "load with a syntax error",
+			lines: []string{":load DIR/bad.lox", "x;"},
+			out:   "\n",
+			errOut: "DIR/bad.lox:2:9: Expected closing ')'\n\tprint (x;\n\t        ^\n" +
+				"<repl:1>:1:1: Undefined variable \"x\".\n\tx;\n\t^\n",
+		},
+		{
+			name:   "load a missing file",
+			lines:  []string{":load DIR/missing.lox"},
+			out:    "\n",
+			errOut: "open DIR/missing.lox: no such file or directory\n",
+		},
+		{
+			name:   "unknown command",
+			lines:  []string{":nope"},
+			out:    "\n",
+			errOut: "unknown command \":nope\", try :help\n",
+		},
+	}
+
+	took := regexp.MustCompile(`(?m)^took .*$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+			r := &repl{interp: glox.NewInterpreter(out), out: out, errOut: errOut}
+			lines := make([]string, len(tt.lines))
+			for j, l := range tt.lines {
+				lines[j] = strings.ReplaceAll(l, "DIR", dir)
+			}
+			s := &scripted{lines: lines, out: out}
+			if err := r.run(&promptReader{repl: r, line: s}); err != nil {
+				t.Fatalf("run: %s", err)
+			}
+
+			got := took.ReplaceAllString(out.String(), "took")
+			if d := cmp.Diff(tt.out, got); d != "" {
+				t.Errorf("printed diff (-want, +got):\n%s", d)
+			}
+			gotErr := strings.ReplaceAll(errOut.String(), dir, "DIR")
+			if d := cmp.Diff(tt.errOut, gotErr); d != "" {
+				t.Errorf("errors diff (-want, +got):\n%s", d)
+			}
+		})
+	}
+}
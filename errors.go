@@ -0,0 +1,341 @@
+package glox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrorCode classifies an error, so callers need not match on messages.
+type ErrorCode int
+
+const (
+	CodeUnknown ErrorCode = iota
+
+	// Scanning.
+	CodeUnexpectedChar
+	CodeUnterminatedString
+	CodeInvalidEscape
+	CodeUnterminatedComment
+	CodeInvalidNumber
+	CodeInvalidUTF8
+
+	// Parsing.
+	CodeUnexpectedToken
+	CodeInvalidAssignment
+	CodeTooManyArguments
+
+	// Resolving.
+	CodeOwnInitializer
+	CodeRedeclared
+	CodeTopLevelReturn
+	CodeInitializerReturn
+	CodeThisOutsideClass
+	CodeSuperOutsideClass
+	CodeSuperWithoutSuperclass
+	CodeSelfInheritance
+	CodeOutsideLoop
+
+	// Runtime.
+	CodeUndefinedVariable
+	CodeUndefinedProperty
+	CodeOperandType
+	CodeNotCallable
+	CodeArity
+	CodeNotInstance
+	CodeSuperclassType
+	CodeDivisionByZero
+	CodeNotIndexable
+	CodeIndexOutOfRange
+	CodeUndefinedKey
+	// CodeThrown is a value thrown by the script and never caught.
+	CodeThrown
+	// CodeNative is an error returned by a Go function, see RegisterFunc.
+	CodeNative
+	// CodeConversion is a value without a counterpart in Lox or Go,
+	// see RegisterStruct.
+	CodeConversion
+	// CodeInternal means glox is broken, not the script.
+	CodeInternal
+)
+
+var errorCodes = map[ErrorCode]string{
+	CodeUnknown: "unknown",
+
+	CodeUnexpectedChar:      "unexpected-char",
+	CodeUnterminatedString:  "unterminated-string",
+	CodeInvalidEscape:       "invalid-escape",
+	CodeUnterminatedComment: "unterminated-comment",
+	CodeInvalidNumber:       "invalid-number",
+	CodeInvalidUTF8:         "invalid-utf8",
+
+	CodeUnexpectedToken:   "unexpected-token",
+	CodeInvalidAssignment: "invalid-assignment",
+	CodeTooManyArguments:  "too-many-arguments",
+
+	CodeOwnInitializer:         "own-initializer",
+	CodeRedeclared:             "redeclared",
+	CodeTopLevelReturn:         "top-level-return",
+	CodeInitializerReturn:      "initializer-return",
+	CodeThisOutsideClass:       "this-outside-class",
+	CodeSuperOutsideClass:      "super-outside-class",
+	CodeSuperWithoutSuperclass: "super-without-superclass",
+	CodeSelfInheritance:        "self-inheritance",
+	CodeOutsideLoop:            "outside-loop",
+
+	CodeUndefinedVariable: "undefined-variable",
+	CodeUndefinedProperty: "undefined-property",
+	CodeOperandType:       "operand-type",
+	CodeNotCallable:       "not-callable",
+	CodeArity:             "arity",
+	CodeNotInstance:       "not-instance",
+	CodeSuperclassType:    "superclass-type",
+	CodeDivisionByZero:    "division-by-zero",
+	CodeNotIndexable:      "not-indexable",
+	CodeIndexOutOfRange:   "index-out-of-range",
+	CodeUndefinedKey:      "undefined-key",
+	CodeThrown:            "thrown",
+	CodeNative:            "native",
+	CodeConversion:        "conversion",
+	CodeInternal:          "internal",
+}
+
+func (c ErrorCode) String() string {
+	return errorCodes[c]
+}
+
+// ScanError is an invalid token in the source.
+type ScanError struct {
+	// Pos and End of the offending lexeme.
+	Pos, End Position
+	Code     ErrorCode
+	Msg      string
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Unwrap to io.ErrUnexpectedEOF if the input ended too soon.
+func (e *ScanError) Unwrap() error {
+	if e.Code == CodeUnterminatedString || e.Code == CodeUnterminatedComment {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// SyntaxError is a problem found while parsing.
+type SyntaxError struct {
+	// Pos and End of the offending token.
+	Pos, End Position
+	Code     ErrorCode
+	Msg      string
+	// Expected describes what the parser wanted, if anything in particular.
+	Expected string
+	// Found instead.
+	Found Token
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Unwrap to io.ErrUnexpectedEOF if the input ended too soon.
+func (e *SyntaxError) Unwrap() error {
+	if e.Found.Kind == EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// SyntaxErrors in the order they were found.
+type SyntaxErrors []*SyntaxError
+
+func (l SyntaxErrors) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l SyntaxErrors) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// ResolveError is a misuse of names found before running, like returning
+// from top-level code.
+type ResolveError struct {
+	// Pos and End of the offending lexeme.
+	Pos, End Position
+	Code     ErrorCode
+	Msg      string
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ResolveErrors in the order they were found.
+type ResolveErrors []*ResolveError
+
+func (l ResolveErrors) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ResolveErrors) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// RuntimeError stops the interpreter.
+type RuntimeError struct {
+	// Pos and End of the expression which failed.
+	Pos, End Position
+	Code     ErrorCode
+	Msg      string
+	// Stack of calls when the error happened, innermost first.
+	Stack []Frame
+
+	// thrown is set if the script threw value.
+	thrown bool
+	value  any
+	// err returned by a Go function.
+	err error
+}
+
+func (e *RuntimeError) Error() string {
+	// Like calling a value from Go, which is not in any script.
+	if e.Pos.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Unwrap to the error a Go function returned, if any.
+func (e *RuntimeError) Unwrap() error {
+	return e.err
+}
+
+// Traceback of e, one line per frame of its stack, innermost first.
+func (e *RuntimeError) Traceback() string {
+	lines := make([]string, len(e.Stack))
+	for i, f := range e.Stack {
+		lines[i] = f.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Frame of a call stack.
+type Frame struct {
+	// Function running, "<script>" for top-level code.
+	Function string
+	// Class of the method running, if any.
+	Class string
+	// Pos executing in the function.
+	Pos Position
+}
+
+// String like "at Foo.bar (shapes.lox:14)".
+func (f Frame) String() string {
+	name := f.Function
+	if f.Class != "" {
+		name = f.Class + "." + name
+	}
+	if f.Pos.File == "" {
+		return fmt.Sprintf("at %s (line %d)", name, f.Pos.Line)
+	}
+	return fmt.Sprintf("at %s (%s:%d)", name, f.Pos.File, f.Pos.Line)
+}
+
+func (e *ScanError) span() (Position, Position)    { return e.Pos, e.End }
+func (e *SyntaxError) span() (Position, Position)  { return e.Pos, e.End }
+func (e *ResolveError) span() (Position, Position) { return e.Pos, e.End }
+func (e *RuntimeError) span() (Position, Position) { return e.Pos, e.End }
+
+// spanner is an error pointing into the source.
+type spanner interface {
+	span() (pos, end Position)
+}
+
+// FormatError err with the line of src it points at, and a caret under the
+// offending lexeme, if err is in file and src is its source. Errors without
+// a position, or elsewhere, are formatted as is. Lists of errors are
+// formatted one by one. Runtime errors get a traceback.
+func FormatError(file string, src []byte, err error) string {
+	if l, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := l.Unwrap()
+		lines := make([]string, len(errs))
+		for i, e := range errs {
+			lines[i] = FormatError(file, src, e)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	var sp spanner
+	if !errors.As(err, &sp) {
+		return err.Error()
+	}
+	msg := err.Error()
+	if pos, end := sp.span(); pos.File == file && pos.Line > 0 && pos.Offset <= len(src) {
+		msg += "\n" + pointAt(src, pos, end)
+	}
+
+	// A lone top-level frame says nothing the caret does not.
+	var re *RuntimeError
+	if errors.As(err, &re) && len(re.Stack) > 1 {
+		msg += "\n" + re.Traceback()
+	}
+	return msg
+}
+
+// pointAt the line of src from pos, with a caret under each rune up to end.
+func pointAt(src []byte, pos, endPos Position) string {
+	at := pos.Offset
+
+	start := bytes.LastIndexByte(src[:at], '\n') + 1
+	end := bytes.IndexByte(src[at:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += at
+	}
+
+	// Indent with tabs where the line does, so the caret lines up.
+	indent := bytes.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, src[start:at])
+
+	n := endPos.Offset - at
+	if n > end-at {
+		n = end - at
+	}
+	// One caret per rune, not byte.
+	n = utf8.RuneCount(src[at : at+n])
+	if n < 1 {
+		n = 1
+	}
+
+	return fmt.Sprintf("\t%s\n\t%s%s", src[start:end], indent, strings.Repeat("^", n))
+}
@@ -0,0 +1,205 @@
+package glox_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vikblom/glox"
+)
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "print 1 +;", want: "test.lox:1:10: Expected expression"},
+		{src: "var a = 1;\n1 = a;", want: "test.lox:2:3: Invalid assignment target."},
+		{src: "var a = 1;\n  print b;", want: `test.lox:2:9: Undefined variable "b".`},
+		{src: "fun f() {}\nf(1);", want: "test.lox:2:4: Expected 0 arguments but got 1"},
+		{src: "{ var a = a; }", want: "test.lox:1:11: Cannot read local variable in its own initializer."},
+		{src: "print 1;\nprint @;", want: `test.lox:2:7: Unexpected character "@".`},
+	}
+
+	for _, tt := range tests {
+		err := run("test.lox", tt.src)
+		if err == nil {
+			t.Fatalf("expected %q to fail", tt.src)
+		}
+		if got := err.Error(); got != tt.want {
+			t.Errorf("run(%q) = %q but want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	src := "var a = 1;\n\tprint a + nope;\n"
+	err := run("test.lox", src)
+
+	want := "test.lox:2:12: Undefined variable \"nope\".\n" +
+		"\t\tprint a + nope;\n" +
+		"\t\t          ^^^^"
+	if got := glox.FormatError("test.lox", []byte(src), err); got != want {
+		t.Errorf("FormatError() =\n%s\nbut want\n%s", got, want)
+	}
+
+	// Columns and carets count runes.
+	src = "var ø = \"å\" + größe;\n"
+	err = run("test.lox", src)
+	want = "test.lox:1:15: Undefined variable \"größe\".\n" +
+		"\tvar ø = \"å\" + größe;\n" +
+		"\t              ^^^^^"
+	if got := glox.FormatError("test.lox", []byte(src), err); got != want {
+		t.Errorf("FormatError() =\n%s\nbut want\n%s", got, want)
+	}
+
+	// Errors in another file have no line of src to point at.
+	want = "test.lox:1:15: Undefined variable \"größe\"."
+	if got := glox.FormatError("other.lox", []byte(src), err); got != want {
+		t.Errorf("FormatError() =\n%s\nbut want\n%s", got, want)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		kind string
+		want glox.ErrorCode
+	}{
+		{src: "print \"foo", kind: "scan", want: glox.CodeUnterminatedString},
+		{src: "print ~;", kind: "scan", want: glox.CodeUnexpectedChar},
+		{src: "print \"\xff\";", kind: "scan", want: glox.CodeInvalidUTF8},
+		{src: "print 1 +;", kind: "syntax", want: glox.CodeUnexpectedToken},
+		{src: "1 = 2;", kind: "syntax", want: glox.CodeInvalidAssignment},
+		{src: "return 1;", kind: "resolve", want: glox.CodeTopLevelReturn},
+		{src: "print this;", kind: "resolve", want: glox.CodeThisOutsideClass},
+		{src: "print nope;", kind: "runtime", want: glox.CodeUndefinedVariable},
+		{src: "print 1 - nil;", kind: "runtime", want: glox.CodeOperandType},
+		{src: "print 1();", kind: "runtime", want: glox.CodeNotCallable},
+		{src: "class A {} A().b;", kind: "runtime", want: glox.CodeUndefinedProperty},
+		{src: "print nil[0];", kind: "runtime", want: glox.CodeNotIndexable},
+		{src: "print [][0];", kind: "runtime", want: glox.CodeIndexOutOfRange},
+		{src: "print {a: 1}[\"b\"];", kind: "runtime", want: glox.CodeUndefinedKey},
+		{src: "throw Error(\"x\");", kind: "runtime", want: glox.CodeThrown},
+	}
+
+	for _, tt := range tests {
+		err := run("test.lox", tt.src)
+		kind, code := classify(err)
+		if kind != tt.kind || code != tt.want {
+			t.Errorf("run(%q) gave %s error %s but want %s error %s: %v", tt.src, kind, code, tt.kind, tt.want, err)
+		}
+	}
+}
+
+func TestRuntimeErrorStack(t *testing.T) {
+	src := `fun a() {
+  b();
+}
+fun b() {
+  nope;
+}
+a();
+`
+	err := run("test.lox", src)
+	var re *glox.RuntimeError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected runtime error but got %T: %v", err, err)
+	}
+
+	want := []glox.Frame{
+		{Function: "b", Pos: glox.Position{File: "test.lox", Line: 5, Column: 3, Offset: 31}},
+		{Function: "a", Pos: glox.Position{File: "test.lox", Line: 2, Column: 5, Offset: 14}},
+		{Function: "<script>", Pos: glox.Position{File: "test.lox", Line: 7, Column: 3, Offset: 41}},
+	}
+	if d := cmp.Diff(want, re.Stack); d != "" {
+		t.Errorf("stack diff (-want, +got):\n%s", d)
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	src := `class Shape {
+  init(n) {
+    this.n = n;
+    this.area();
+  }
+}
+class Square < Shape {
+  area() {
+    return this.n * this.side;
+  }
+}
+fun make() {
+  return Square(2);
+}
+make();
+`
+	err := run("shapes.lox", src)
+	var re *glox.RuntimeError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected runtime error but got %T: %v", err, err)
+	}
+
+	want := `at Square.area (shapes.lox:9)
+at Shape.init (shapes.lox:4)
+at make (shapes.lox:13)
+at <script> (shapes.lox:15)`
+	if got := re.Traceback(); got != want {
+		t.Errorf("traceback:\n%s\nbut want:\n%s", got, want)
+	}
+}
+
+func TestStackResetAfterError(t *testing.T) {
+	i := glox.NewInterpreter(io.Discard)
+	for _, src := range []string{"fun f() { nope; } f();", "nope;"} {
+		toks, err := glox.ScanString(src)
+		if err != nil {
+			t.Fatalf("scan string: %s", err)
+		}
+		stmts, err := glox.NewParser(toks).Parse()
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		err = i.Interpret(stmts)
+		var re *glox.RuntimeError
+		if !errors.As(err, &re) {
+			t.Fatalf("expected runtime error but got %T: %v", err, err)
+		}
+		if src == "nope;" && len(re.Stack) != 1 {
+			t.Errorf("expected only the script frame but got:\n%s", re.Traceback())
+		}
+	}
+}
+
+// classify err like an embedder would.
+func classify(err error) (string, glox.ErrorCode) {
+	var scanErr *glox.ScanError
+	var syntaxErr *glox.SyntaxError
+	var resolveErr *glox.ResolveError
+	var runtimeErr *glox.RuntimeError
+	switch {
+	case errors.As(err, &scanErr):
+		return "scan", scanErr.Code
+	case errors.As(err, &syntaxErr):
+		return "syntax", syntaxErr.Code
+	case errors.As(err, &resolveErr):
+		return "resolve", resolveErr.Code
+	case errors.As(err, &runtimeErr):
+		return "runtime", runtimeErr.Code
+	}
+	return "unknown", glox.CodeUnknown
+}
+
+// run src from file until the first error.
+func run(file, src string) error {
+	toks, err := glox.ScanFile(file, []byte(src))
+	if err != nil {
+		return err
+	}
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		return err
+	}
+	return glox.NewInterpreter(io.Discard).Interpret(stmts)
+}
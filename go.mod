@@ -4,5 +4,11 @@ go 1.20
 
 require (
 	github.com/google/go-cmp v0.6.0
+	github.com/peterh/liner v1.2.2
 	golang.org/x/tools v0.15.0
 )
+
+require (
+	github.com/mattn/go-runewidth v0.0.3 // indirect
+	golang.org/x/sys v0.14.0 // indirect
+)
@@ -0,0 +1,121 @@
+package glox
+
+import (
+	"fmt"
+)
+
+// LoxList is the value of a list literal like [1, 2, 3].
+type LoxList struct {
+	elems []any
+}
+
+func (l *LoxList) String() string {
+	return inspect(l, map[any]bool{})
+}
+
+// get a method of l, like xs.push.
+func (l *LoxList) get(name Token) any {
+	switch name.Literal {
+	case "len":
+		return &nativeMethod{class: "list", name: name, params: 0, fn: func(_ []any) any {
+			return int64(len(l.elems))
+		}}
+
+	case "push":
+		return &nativeMethod{class: "list", name: name, params: 1, fn: func(args []any) any {
+			l.elems = append(l.elems, args[0])
+			return nil
+		}}
+
+	case "pop":
+		return &nativeMethod{class: "list", name: name, params: 0, fn: func(_ []any) any {
+			if len(l.elems) == 0 {
+				runtimeErrf(name, CodeIndexOutOfRange, "Can't pop from an empty list.")
+			}
+			v := l.elems[len(l.elems)-1]
+			l.elems = l.elems[:len(l.elems)-1]
+			return v
+		}}
+
+	case "insert":
+		// Insert so that xs[i] is v afterwards, -1 appends.
+		return &nativeMethod{class: "list", name: name, params: 2, fn: func(args []any) any {
+			i := l.index(name, args[0], len(l.elems)+1)
+			l.elems = append(l.elems, nil)
+			copy(l.elems[i+1:], l.elems[i:])
+			l.elems[i] = args[1]
+			return nil
+		}}
+
+	case "remove":
+		// Remove the element at i and return it.
+		return &nativeMethod{class: "list", name: name, params: 1, fn: func(args []any) any {
+			i := l.index(name, args[0], len(l.elems))
+			v := l.elems[i]
+			l.elems = append(l.elems[:i], l.elems[i+1:]...)
+			return v
+		}}
+	}
+
+	runtimeErrf(name, CodeUndefinedProperty, "Undefined property %q on list.", name.Literal)
+	return nil
+}
+
+// index v into the n positions of l, counting from the end if negative.
+func (l *LoxList) index(at Token, v any, n int) int {
+	i, ok := v.(int64)
+	if !ok {
+		runtimeErrf(at, CodeOperandType, "List index must be an integer: %s", Stringify(v))
+	}
+	j := i
+	if j < 0 {
+		j += int64(n)
+	}
+	if j < 0 || j >= int64(n) {
+		runtimeErrf(at, CodeIndexOutOfRange, "Index %d out of range for list of length %d.", i, len(l.elems))
+	}
+	return int(j)
+}
+
+// slice l from lo to hi, either may be nil for the start and end.
+func (l *LoxList) slice(at Token, lo, hi any) *LoxList {
+	bound := func(v any, def int) int64 {
+		if v == nil {
+			return int64(def)
+		}
+		i, ok := v.(int64)
+		if !ok {
+			runtimeErrf(at, CodeOperandType, "Slice bounds must be integers: %s", Stringify(v))
+		}
+		if i < 0 {
+			i += int64(len(l.elems))
+		}
+		return i
+	}
+	i, j := bound(lo, 0), bound(hi, len(l.elems))
+	if i < 0 || j < i || j > int64(len(l.elems)) {
+		runtimeErrf(at, CodeIndexOutOfRange, "Slice bounds out of range for list of length %d.", len(l.elems))
+	}
+	elems := make([]any, j-i)
+	copy(elems, l.elems[i:j])
+	return &LoxList{elems: elems}
+}
+
+// nativeMethod is a method implemented in Go, bound to its receiver.
+type nativeMethod struct {
+	class string
+	// name the method was looked up by, where errors are reported.
+	name   Token
+	params int
+	fn     func(args []any) any
+}
+
+func (m *nativeMethod) arity() int { return m.params }
+
+func (m *nativeMethod) call(_ *Interpreter, args []any) any {
+	return m.fn(args)
+}
+
+func (m *nativeMethod) String() string {
+	return fmt.Sprintf("<native fn %s.%s>", m.class, m.name.Literal)
+}
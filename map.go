@@ -0,0 +1,105 @@
+package glox
+
+import (
+	"math"
+)
+
+// LoxMap is the value of a map literal like {"a": 1, b: 2}.
+// Keys are kept in the order they were first set.
+// Any value can be a key, and keys are the same if they are ==.
+type LoxMap struct {
+	keys, values []any
+	// index of each key in keys, by mapKey.
+	index map[any]int
+}
+
+func newMap() *LoxMap {
+	return &LoxMap{index: map[any]int{}}
+}
+
+// mapKey normalizes v so that numbers equal by value are the same key,
+// as are Go values proxied more than once.
+func mapKey(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v)
+		}
+	case *goInstance:
+		return v.key()
+	}
+	return v
+}
+
+func (m *LoxMap) String() string {
+	return inspect(m, map[any]bool{})
+}
+
+func (m *LoxMap) lookup(key any) (any, bool) {
+	i, ok := m.index[mapKey(key)]
+	if !ok {
+		return nil, false
+	}
+	return m.values[i], true
+}
+
+func (m *LoxMap) set(key, v any) {
+	k := mapKey(key)
+	if i, ok := m.index[k]; ok {
+		m.values[i] = v
+		return
+	}
+	m.index[k] = len(m.keys)
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, v)
+}
+
+// delete key from m, reporting if it was there.
+func (m *LoxMap) delete(key any) bool {
+	k := mapKey(key)
+	i, ok := m.index[k]
+	if !ok {
+		return false
+	}
+	delete(m.index, k)
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+	m.values = append(m.values[:i], m.values[i+1:]...)
+	for j := i; j < len(m.keys); j++ {
+		m.index[mapKey(m.keys[j])] = j
+	}
+	return true
+}
+
+// get a method of m, like m.keys.
+func (m *LoxMap) get(name Token) any {
+	switch name.Literal {
+	case "len":
+		return &nativeMethod{class: "map", name: name, params: 0, fn: func(_ []any) any {
+			return int64(len(m.keys))
+		}}
+
+	case "keys":
+		return &nativeMethod{class: "map", name: name, params: 0, fn: func(_ []any) any {
+			return &LoxList{elems: append([]any{}, m.keys...)}
+		}}
+
+	case "values":
+		return &nativeMethod{class: "map", name: name, params: 0, fn: func(_ []any) any {
+			return &LoxList{elems: append([]any{}, m.values...)}
+		}}
+
+	case "has":
+		return &nativeMethod{class: "map", name: name, params: 1, fn: func(args []any) any {
+			_, ok := m.lookup(args[0])
+			return ok
+		}}
+
+	case "delete":
+		return &nativeMethod{class: "map", name: name, params: 1, fn: func(args []any) any {
+			return m.delete(args[0])
+		}}
+	}
+
+	runtimeErrf(name, CodeUndefinedProperty, "Undefined property %q on map.", name.Literal)
+	return nil
+}
@@ -2,12 +2,22 @@ package glox
 
 import (
 	"fmt"
-	"strconv"
+	"io"
 )
 
 type Parser struct {
 	tokens  []Token
 	current int
+	// depth of nested blocks at current.
+	depth int
+
+	// sc to scan tokens from as they are needed, if streaming.
+	sc *Scanner
+	// resync before the next statement, after an error.
+	resync bool
+
+	// errs found so far.
+	errs SyntaxErrors
 }
 
 func NewParser(tokens []Token) *Parser {
@@ -17,35 +27,110 @@ func NewParser(tokens []Token) *Parser {
 	}
 }
 
-type parsingError struct{ error }
-
-// TODO: Should take a token for positioning?
-func parseErrf(format string, args ...any) {
-	panic(parsingError{error: fmt.Errorf(format, args...)})
+// NewStreamParser of tokens from sc, scanned no further than needed.
+// Use Next to parse one statement at a time, keeping little in memory.
+func NewStreamParser(sc *Scanner) *Parser {
+	return &Parser{sc: sc}
 }
 
+type parsingError struct{ *SyntaxError }
+
+// scanningError from the Scanner of a stream parser.
+type scanningError struct{ err error }
+
+// Parse all statements. On syntax errors, the statements which did parse
+// are returned together with SyntaxErrors listing every problem.
 func (p *Parser) Parse() (stmts []Stmt, err error) {
-	// This is the synchronization point.
-	// The book does it inside parseDecl
 	defer func() {
 		if r := recover(); r != nil {
-			if re, ok := r.(parsingError); ok {
-				err = re.error
-			} else {
+			se, ok := r.(scanningError)
+			if !ok {
 				panic(r)
 			}
+			err = se.err
 		}
 	}()
 
+	stmts = []Stmt{}
 	for !p.isAtEnd() {
-		s := p.parseDecl()
-		stmts = append(stmts, s)
+		if s := p.parseDecl(); s != nil {
+			stmts = append(stmts, s)
+		}
+	}
+	if len(p.errs) > 0 {
+		return stmts, p.errs
 	}
 	return stmts, nil
 }
 
-func (p *Parser) parseDecl() Stmt {
-	if p.match(FUN) {
+// Next statement, returning io.EOF after the last one. A syntax error is
+// returned as SyntaxErrors, and the following call skips to the statement
+// after it. A stream parser returns errors from its Scanner too.
+func (p *Parser) Next() (stmt Stmt, err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case scanningError:
+			stmt, err = nil, r.err
+		case parsingError:
+			stmt, err = nil, p.errs
+			p.resync = true
+		default:
+			panic(r)
+		}
+	}()
+
+	p.errs = nil
+	if p.resync {
+		p.resync = false
+		p.depth = 0
+		p.sync()
+	}
+	p.forget()
+	if p.isAtEnd() {
+		return nil, io.EOF
+	}
+
+	stmt = p.declaration()
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return stmt, nil
+}
+
+// forget tokens parsed, but the previous one, when streaming.
+func (p *Parser) forget() {
+	if p.sc == nil || p.current < 2 {
+		return
+	}
+	n := copy(p.tokens, p.tokens[p.current-1:])
+	p.tokens = p.tokens[:n]
+	p.current = 1
+}
+
+// parseDecl is the synchronization point, like in the book.
+// On a syntax error, it skips to the next statement and returns nil.
+func (p *Parser) parseDecl() (stmt Stmt) {
+	depth := p.depth
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(parsingError); !ok {
+				panic(r)
+			}
+			p.depth = depth
+			p.sync()
+			stmt = nil
+		}
+	}()
+
+	return p.declaration()
+}
+
+// declaration or statement.
+func (p *Parser) declaration() Stmt {
+	// An anonymous function starts an expression statement instead.
+	if p.check(FUN) && p.peekAt(1).Kind != PAREN_LEFT {
+		p.advance()
 		return p.parseFuncStmt("function")
 	}
 	if p.match(VAR) {
@@ -58,15 +143,28 @@ func (p *Parser) parseDecl() Stmt {
 }
 
 func (p *Parser) parseFuncStmt(kind string) Stmt {
+	var keyword Token
+	if p.current > 0 && p.previous().Kind == FUN {
+		keyword = p.previous()
+	}
 	name := p.consume(IDENTIFIER, fmt.Sprintf("Expect %q name.", kind))
 
 	p.consume(PAREN_LEFT, fmt.Sprintf("Expected opening '(' after %s name.", kind))
+	params := p.parseParams(kind)
+
+	p.consume(BRACE_LEFT, fmt.Sprintf("Expected '{' before %s body.", kind))
+	body := p.parseBlockStmt()
 
+	return &FuncStmt{keyword: keyword, name: name, params: params, body: []Stmt{body}}
+}
+
+// parseParams after the '(', up to and including the ')'.
+func (p *Parser) parseParams(kind string) []Token {
 	params := []Token{}
 	if !p.check(PAREN_RIGHT) {
 		for {
 			if len(params) > 255 {
-				parseErrf("Can't have more than 255 parameters.")
+				p.report(p.peek(), CodeTooManyArguments, "", "Can't have more than 255 parameters.")
 			}
 			params = append(params, p.consume(IDENTIFIER, "Expect parameter name."))
 			if !p.match(COMMA) {
@@ -75,14 +173,57 @@ func (p *Parser) parseFuncStmt(kind string) Stmt {
 		}
 	}
 	p.consume(PAREN_RIGHT, fmt.Sprintf("Expected closing ')' after %s params.", kind))
+	return params
+}
 
-	p.consume(BRACE_LEFT, fmt.Sprintf("Expected '{' before %s body.", kind))
+// parseFuncExpr after 'fun', like fun (a) { return a; }
+func (p *Parser) parseFuncExpr() Expr {
+	keyword := p.previous()
+	p.consume(PAREN_LEFT, "Expected opening '(' after 'fun'.")
+	params := p.parseParams("function")
+
+	p.consume(BRACE_LEFT, "Expected '{' before function body.")
 	body := p.parseBlockStmt()
 
-	return &FuncStmt{name: name, params: params, body: []Stmt{body}}
+	return &FuncExpr{decl: &FuncStmt{keyword: keyword, params: params, body: []Stmt{body}}}
+}
+
+// parseArrowFunc after the '(', like (a) => a.
+func (p *Parser) parseArrowFunc() Expr {
+	lparen := p.previous()
+	params := p.parseParams("function")
+	arrow := p.consume(ARROW, "Expected '=>' after function params.")
+	value := p.parseExpr()
+
+	// Runs like fun (a) { return a; }
+	ret := &ReturnStmt{keyword: arrow, value: value}
+	return &FuncExpr{decl: &FuncStmt{keyword: lparen, params: params, body: []Stmt{ret}}, arrow: value}
+}
+
+// isArrowFunc if the tokens ahead look like "(a, b) =>".
+func (p *Parser) isArrowFunc() bool {
+	if !p.check(PAREN_LEFT) {
+		return false
+	}
+	n := 1
+	if p.peekAt(n).Kind == PAREN_RIGHT {
+		return p.peekAt(n+1).Kind == ARROW
+	}
+	for p.peekAt(n).Kind == IDENTIFIER {
+		switch p.peekAt(n + 1).Kind {
+		case COMMA:
+			n += 2
+		case PAREN_RIGHT:
+			return p.peekAt(n+2).Kind == ARROW
+		default:
+			return false
+		}
+	}
+	return false
 }
 
 func (p *Parser) parseVarStmt() Stmt {
+	keyword := p.previous()
 	name := p.consume(IDENTIFIER, "Expected variable name.")
 
 	var init Expr
@@ -90,11 +231,12 @@ func (p *Parser) parseVarStmt() Stmt {
 		init = p.parseExpr()
 	}
 
-	p.consume(SEMICOLON, "Expected terminating ';' after print value.")
-	return &VarStmt{name: name, init: init}
+	semicolon := p.consume(SEMICOLON, "Expected terminating ';' after print value.")
+	return &VarStmt{keyword: keyword, name: name, init: init, semicolon: semicolon}
 }
 
 func (p *Parser) parseClassStmt() Stmt {
+	keyword := p.previous()
 	name := p.consume(IDENTIFIER, "Expected class name.")
 
 	var super *Variable
@@ -104,13 +246,15 @@ func (p *Parser) parseClassStmt() Stmt {
 	}
 
 	p.consume(BRACE_LEFT, "Expected '{' before class body.")
+	p.depth++
 	methods := []Stmt{}
 	for !p.check(BRACE_RIGHT) && !p.isAtEnd() {
 		methods = append(methods, p.parseFuncStmt("method"))
 
 	}
-	p.consume(BRACE_RIGHT, "Expected '}' afterclass body.")
-	return &ClassStmt{name: name, super: super, methods: methods}
+	p.depth--
+	rbrace := p.consume(BRACE_RIGHT, "Expected '}' afterclass body.")
+	return &ClassStmt{keyword: keyword, name: name, super: super, methods: methods, rbrace: rbrace}
 }
 
 func (p *Parser) parseStmt() Stmt {
@@ -129,13 +273,55 @@ func (p *Parser) parseStmt() Stmt {
 	if p.match(FOR) {
 		return p.parseForStmt()
 	}
+	if p.match(BREAK) {
+		keyword := p.previous()
+		semicolon := p.consume(SEMICOLON, "Expected ';' after 'break'.")
+		return &BreakStmt{keyword: keyword, semicolon: semicolon}
+	}
+	if p.match(CONTINUE) {
+		keyword := p.previous()
+		semicolon := p.consume(SEMICOLON, "Expected ';' after 'continue'.")
+		return &ContinueStmt{keyword: keyword, semicolon: semicolon}
+	}
+	if p.match(THROW) {
+		keyword := p.previous()
+		value := p.parseExpr()
+		semicolon := p.consume(SEMICOLON, "Expected terminating ';' after thrown value.")
+		return &ThrowStmt{keyword: keyword, value: value, semicolon: semicolon}
+	}
+	if p.match(TRY) {
+		return p.parseTryStmt()
+	}
 	if p.match(BRACE_LEFT) {
 		return p.parseBlockStmt()
 	}
 	return p.parseExprStmt()
 }
 
+func (p *Parser) parseTryStmt() Stmt {
+	stmt := &TryStmt{keyword: p.previous()}
+	p.consume(BRACE_LEFT, "Expected '{' after 'try'.")
+	stmt.body = p.parseBlockStmt().(*BlockStmt)
+
+	if p.match(CATCH) {
+		p.consume(PAREN_LEFT, "Expected opening '(' after 'catch'.")
+		stmt.name = p.consume(IDENTIFIER, "Expected name of caught error.")
+		p.consume(PAREN_RIGHT, "Expected closing ')' after caught error.")
+		p.consume(BRACE_LEFT, "Expected '{' before catch body.")
+		stmt.catch = p.parseBlockStmt().(*BlockStmt)
+	}
+	if p.match(FINALLY) {
+		p.consume(BRACE_LEFT, "Expected '{' after 'finally'.")
+		stmt.finally = p.parseBlockStmt().(*BlockStmt)
+	}
+	if stmt.catch == nil && stmt.finally == nil {
+		p.error(p.peek(), "'catch' or 'finally'", "Expected 'catch' or 'finally' after try block.")
+	}
+	return stmt
+}
+
 func (p *Parser) parseIfStmt() Stmt {
+	keyword := p.previous()
 	p.consume(PAREN_LEFT, "Expected opening '(' for if condition.")
 	cond := p.parseExpr()
 	p.consume(PAREN_RIGHT, "Expected closing ')' for if condition.")
@@ -148,13 +334,14 @@ func (p *Parser) parseIfStmt() Stmt {
 		elseBranch = p.parseStmt()
 	}
 
-	return &IfStmt{cond: cond, thenBranch: thenBranch, elseBranch: elseBranch}
+	return &IfStmt{keyword: keyword, cond: cond, thenBranch: thenBranch, elseBranch: elseBranch}
 }
 
 func (p *Parser) parsePrintStmt() Stmt {
+	keyword := p.previous()
 	val := p.parseExpr()
-	p.consume(SEMICOLON, "Expected terminating ';' after print value.")
-	return &PrintStmt{expr: val}
+	semicolon := p.consume(SEMICOLON, "Expected terminating ';' after print value.")
+	return &PrintStmt{keyword: keyword, expr: val, semicolon: semicolon}
 }
 
 func (p *Parser) parseReturnStmt() Stmt {
@@ -163,19 +350,21 @@ func (p *Parser) parseReturnStmt() Stmt {
 	if !p.check(SEMICOLON) {
 		value = p.parseExpr()
 	}
-	p.consume(SEMICOLON, "Expected terminating ';' after return value.")
-	return &ReturnStmt{keyword: keyword, value: value}
+	semicolon := p.consume(SEMICOLON, "Expected terminating ';' after return value.")
+	return &ReturnStmt{keyword: keyword, value: value, semicolon: semicolon}
 }
 
 func (p *Parser) parseWhileStmt() Stmt {
+	keyword := p.previous()
 	p.consume(PAREN_LEFT, "Expected opening '(' for while condition.")
 	cond := p.parseExpr()
 	p.consume(PAREN_RIGHT, "Expected closing ')' for while condition.")
 	body := p.parseStmt()
-	return &WhileStmt{cond: cond, body: body}
+	return &WhileStmt{keyword: keyword, cond: cond, body: body}
 }
 
 func (p *Parser) parseForStmt() Stmt {
+	keyword := p.previous()
 	p.consume(PAREN_LEFT, "Expected opening '(' after 'for'.")
 
 	var init Stmt
@@ -192,7 +381,7 @@ func (p *Parser) parseForStmt() Stmt {
 	if !p.check(SEMICOLON) {
 		cond = p.parseExpr()
 	} else {
-		cond = &Literal{val: true}
+		cond = &Literal{tok: p.peek(), val: true}
 	}
 	p.consume(SEMICOLON, "Expected ';' after for loop condition.")
 
@@ -203,51 +392,49 @@ func (p *Parser) parseForStmt() Stmt {
 	p.consume(PAREN_RIGHT, "Expected ')' after for loop incrementor.")
 
 	body := p.parseStmt()
+	// Synthesized nodes span the whole for loop.
+	last := p.previous()
 
-	// De-sugar into a while loop:
+	// De-sugar into a while loop, which runs incr after each body,
+	// even if it continues:
 	// {
 	//    *init*
-	//    while (*cond*) {
-	//        *body*
-	//        *incr*
-	//    }
+	//    while (*cond*) *body* *incr*
 	// }
-	if incr != nil {
-		body = &BlockStmt{
-			statements: []Stmt{
-				body,
-				&ExprStmt{expr: incr},
-			},
-		}
-	}
-
-	body = &WhileStmt{cond: cond, body: body}
+	body = &WhileStmt{keyword: keyword, cond: cond, body: body, incr: incr}
 
 	if init != nil {
 		body = &BlockStmt{
+			lbrace: keyword,
 			statements: []Stmt{
 				init,
 				body,
 			},
+			rbrace: last,
 		}
 	}
 
-	return &WhileStmt{cond: cond, body: body}
+	return body
 }
 
 func (p *Parser) parseBlockStmt() Stmt {
+	lbrace := p.previous()
+	p.depth++
 	stmts := []Stmt{}
 	for !p.check(BRACE_RIGHT) && !p.isAtEnd() {
-		stmts = append(stmts, p.parseDecl())
+		if s := p.parseDecl(); s != nil {
+			stmts = append(stmts, s)
+		}
 	}
-	p.consume(BRACE_RIGHT, "Expected closing '}' after block.")
-	return &BlockStmt{statements: stmts}
+	p.depth--
+	rbrace := p.consume(BRACE_RIGHT, "Expected closing '}' after block.")
+	return &BlockStmt{lbrace: lbrace, statements: stmts, rbrace: rbrace}
 }
 
 func (p *Parser) parseExprStmt() Stmt {
 	val := p.parseExpr()
-	p.consume(SEMICOLON, "Expected terminating ';' after expression.")
-	return &ExprStmt{expr: val}
+	semicolon := p.consume(SEMICOLON, "Expected terminating ';' after expression.")
+	return &ExprStmt{expr: val, semicolon: semicolon}
 }
 
 func (p *Parser) parseExpr() Expr {
@@ -257,14 +444,18 @@ func (p *Parser) parseExpr() Expr {
 func (p *Parser) parseAssign() Expr {
 	expr := p.parseOr()
 	if p.match(EQUAL) {
+		equals := p.previous()
 		value := p.parseAssign()
 		switch v := expr.(type) {
 		case *Variable:
 			return &Assign{name: v.name, val: value}
 		case *GetExpr:
 			return &SetExpr{object: v.object, name: v.name, value: value}
+		case *IndexExpr:
+			return &IndexSetExpr{object: v.object, lbracket: v.lbracket, index: v.index, value: value}
 		default:
-			runtimeErrf("Invalide assignment target %T", expr)
+			// Report, but the parser is not confused so no need to sync.
+			p.report(equals, CodeInvalidAssignment, "", "Invalid assignment target.")
 		}
 	}
 	return expr
@@ -328,7 +519,7 @@ func (p *Parser) parseTerm() Expr {
 
 func (p *Parser) parseFactor() Expr {
 	expr := p.parseUnary()
-	for p.match(STAR, SLASH) {
+	for p.match(STAR, SLASH, PERCENT) {
 		expr = &BinaryExpr{
 			op:    p.previous(),
 			left:  expr,
@@ -359,6 +550,9 @@ func (p *Parser) parseCall() Expr {
 			name := p.consume(IDENTIFIER, "Expected property name after '.'.")
 			expr = &GetExpr{name: name, object: expr}
 
+		} else if p.match(BRACKET_LEFT) {
+			expr = p.finishIndex(expr)
+
 		} else {
 			break
 		}
@@ -371,7 +565,7 @@ func (p *Parser) finishCall(callee Expr) Expr {
 	if !p.check(PAREN_RIGHT) {
 		for {
 			if len(args) > 255 {
-				parseErrf("Can't have more than 255 arguments.")
+				p.report(p.peek(), CodeTooManyArguments, "", "Can't have more than 255 arguments.")
 			}
 			args = append(args, p.parseExpr())
 			if !p.match(COMMA) {
@@ -384,24 +578,104 @@ func (p *Parser) finishCall(callee Expr) Expr {
 	return &Call{callee: callee, paren: paren, args: args}
 }
 
+// finishIndex after the '[', like xs[i] or the slice xs[lo:hi].
+func (p *Parser) finishIndex(object Expr) Expr {
+	lbracket := p.previous()
+	var lo Expr
+	if !p.check(COLON) {
+		lo = p.parseExpr()
+	}
+	if !p.match(COLON) {
+		rbracket := p.consume(BRACKET_RIGHT, "Expected closing ']' after index.")
+		return &IndexExpr{object: object, lbracket: lbracket, index: lo, rbracket: rbracket}
+	}
+
+	var hi Expr
+	if !p.check(BRACKET_RIGHT) {
+		hi = p.parseExpr()
+	}
+	rbracket := p.consume(BRACKET_RIGHT, "Expected closing ']' after slice.")
+	return &SliceExpr{object: object, lbracket: lbracket, lo: lo, hi: hi, rbracket: rbracket}
+}
+
+// parseMap after the '{', like {"a": 1, b: 2}.
+func (p *Parser) parseMap() Expr {
+	lbrace := p.previous()
+	keys, values := []Expr{}, []Expr{}
+	if !p.check(BRACE_RIGHT) {
+		for {
+			// A bare name is a string key, like in {b: 2}.
+			if p.check(IDENTIFIER) && p.peekAt(1).Kind == COLON {
+				name := p.advance()
+				keys = append(keys, &Literal{tok: name, val: name.Literal})
+			} else {
+				keys = append(keys, p.parseExpr())
+			}
+			p.consume(COLON, "Expected ':' after map key.")
+			values = append(values, p.parseExpr())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	rbrace := p.consume(BRACE_RIGHT, "Expected closing '}' after map entries.")
+	return &MapExpr{lbrace: lbrace, keys: keys, values: values, rbrace: rbrace}
+}
+
 func (p *Parser) parsePrimary() Expr {
 	switch {
 	case p.match(FALSE):
-		return &Literal{val: false}
+		return &Literal{tok: p.previous(), val: false}
 	case p.match(TRUE):
-		return &Literal{val: true}
+		return &Literal{tok: p.previous(), val: true}
 	case p.match(NIL):
-		return &Literal{val: nil}
+		return &Literal{tok: p.previous(), val: nil}
 	case p.match(STRING):
-		return &Literal{val: p.previous().Literal}
+		return p.stringPart(p.previous())
+	case p.match(INTERP_BEGIN):
+		parts := []Expr{p.stringPart(p.previous())}
+		for {
+			parts = append(parts, p.parseExpr())
+			if p.match(INTERP_END) {
+				parts = append(parts, p.stringPart(p.previous()))
+				break
+			}
+			mid := p.consume(INTERP_MID, "Expected '}' after interpolated expression.")
+			parts = append(parts, p.stringPart(mid))
+		}
+		return &InterpolationExpr{parts: parts}
 	case p.match(NUMBER):
-		// The book parses floats in the scanner.
-		f, _ := strconv.ParseFloat(p.previous().Literal, 64)
-		return &Literal{val: f}
+		// The scanner already checked the number.
+		n, err := parseNumber(p.previous().Literal)
+		if err != nil {
+			p.error(p.previous(), "", err.Error())
+		}
+		return &Literal{tok: p.previous(), val: n}
+	case p.match(BRACKET_LEFT):
+		lbracket := p.previous()
+		elems := []Expr{}
+		if !p.check(BRACKET_RIGHT) {
+			for {
+				elems = append(elems, p.parseExpr())
+				if !p.match(COMMA) {
+					break
+				}
+			}
+		}
+		rbracket := p.consume(BRACKET_RIGHT, "Expected closing ']' after list elements.")
+		return &ListExpr{lbracket: lbracket, elems: elems, rbracket: rbracket}
+	case p.match(BRACE_LEFT):
+		return p.parseMap()
+	case p.match(FUN):
+		return p.parseFuncExpr()
+	case p.isArrowFunc():
+		p.advance()
+		return p.parseArrowFunc()
 	case p.match(PAREN_LEFT):
+		lparen := p.previous()
 		expr := p.parseExpr()
-		p.consume(PAREN_RIGHT, "Expected closing ')'")
-		return &Grouping{group: expr}
+		rparen := p.consume(PAREN_RIGHT, "Expected closing ')'")
+		return &Grouping{lparen: lparen, group: expr, rparen: rparen}
 	case p.match(IDENTIFIER):
 		return &Variable{name: p.previous()}
 	case p.match(THIS):
@@ -413,12 +687,29 @@ func (p *Parser) parsePrimary() Expr {
 		return &SuperExpr{keyword: keyword, method: method}
 	default:
 		at := p.peek()
-		p.error(at.Line, "Expected expression")
-		p.sync()
+		p.error(at, "expression", "Expected expression")
 		return nil
 	}
 }
 
+// stringPart is the Literal text of a string, or of the part of an
+// interpolated string around the expressions.
+func (p *Parser) stringPart(tok Token) *Literal {
+	// Drop the '"' or '}' in front and the '"' or "${" behind.
+	lit := tok.Literal[1:]
+	if tok.Kind == INTERP_BEGIN || tok.Kind == INTERP_MID {
+		lit = lit[:len(lit)-2]
+	} else {
+		lit = lit[:len(lit)-1]
+	}
+	// The scanner already checked the escapes.
+	val, err := unescape(lit)
+	if err != nil {
+		p.error(tok, "", "Invalid string literal.")
+	}
+	return &Literal{tok: tok, val: val}
+}
+
 // Check if the next token has type tt.
 // Does not move forward.
 func (p *Parser) check(tt TokenType) bool {
@@ -428,7 +719,7 @@ func (p *Parser) check(tt TokenType) bool {
 // match if currently on one of token types.
 // Behaves like a "consume", moving forward if matching.
 func (p *Parser) match(tts ...TokenType) bool {
-	at := p.tokens[p.current]
+	at := p.peek()
 	if at.Kind == EOF {
 		return false
 	}
@@ -444,29 +735,50 @@ func (p *Parser) match(tts ...TokenType) bool {
 func (p *Parser) consume(tt TokenType, msg string) Token {
 	at := p.peek()
 	if at.Kind != tt {
-		p.error(at.Line, msg)
-		p.sync()
+		p.error(at, tt.String(), msg)
 		return Token{Kind: ILLEGAL, Line: at.Line}
 	}
 
 	return p.advance()
 }
 
-func (p *Parser) error(line int, msg string) {
+// report a syntax error at token, where expected was wanted if not empty.
+func (p *Parser) report(at Token, code ErrorCode, expected, msg string) *SyntaxError {
+	err := &SyntaxError{
+		Pos:      at.Pos(),
+		End:      at.EndPos(),
+		Code:     code,
+		Msg:      msg,
+		Expected: expected,
+		Found:    at,
+	}
+	p.errs = append(p.errs, err)
+	return err
+}
+
+// error reports a syntax error and unwinds to the closest statement.
+func (p *Parser) error(at Token, expected, msg string) {
 	// Emulate exceptions, unwinding the stack.
-	parseErrf("error on line %d: %s", line, msg)
+	panic(parsingError{p.report(at, CodeUnexpectedToken, expected, msg)})
 }
 
-// FIXME: This will probably invalidate expectations up the stack?
-// But it should guarantee to make some progress, else we're stuck.
+// sync skips tokens until the start of the next statement.
+// It always makes progress, else we're stuck.
 func (p *Parser) sync() {
+	// An enclosing block makes progress by consuming the '}'.
+	blockEnd := func() bool { return p.depth > 0 && p.check(BRACE_RIGHT) }
+
+	if blockEnd() {
+		return
+	}
 	p.advance()
-	for !p.isAtEnd() {
-		if p.previous().Kind == SEMICOLON {
+	for {
+		// Check the ';' first, a stream need not be read past it.
+		if p.previous().Kind == SEMICOLON || p.isAtEnd() || blockEnd() {
 			return
 		}
 		switch p.peek().Kind {
-		case CLASS, FOR, FUN, IF, PRINT, RETURN, VAR, WHILE:
+		case BREAK, CLASS, CONTINUE, FOR, FUN, IF, PRINT, RETURN, THROW, TRY, VAR, WHILE:
 			return
 		}
 		p.advance()
@@ -489,5 +801,27 @@ func (p *Parser) isAtEnd() bool {
 }
 
 func (p *Parser) peek() Token {
-	return p.tokens[p.current]
+	return p.peekAt(0)
+}
+
+// peekAt the token n ahead of current, or EOF past the end.
+func (p *Parser) peekAt(n int) Token {
+	// Stream parsers scan on demand.
+	for p.sc != nil && p.current+n >= len(p.tokens) {
+		if l := len(p.tokens); l > 0 && p.tokens[l-1].Kind == EOF {
+			break
+		}
+		tok := p.sc.Scan()
+		switch tok.Kind {
+		case COMMENT:
+			continue
+		case ILLEGAL:
+			panic(scanningError{p.sc.Err()})
+		}
+		p.tokens = append(p.tokens, tok)
+	}
+	if p.current+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.current+n]
 }
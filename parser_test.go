@@ -1,6 +1,10 @@
 package glox_test
 
 import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/vikblom/glox"
@@ -51,3 +55,143 @@ func TestParseSyntaxError(t *testing.T) {
 	}
 
 }
+
+func TestParseIncomplete(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "{", want: true},
+		{src: "print (1 + 2", want: true},
+		{src: "fun f() { return 1;", want: true},
+		{src: "var a = 1", want: true},
+		{src: "1 +", want: true},
+		{src: "1 + ;", want: false},
+		{src: "print 1; }", want: false},
+		{src: `print "a ${b`, want: true},
+		{src: `print "a ${} b";`, want: false},
+	}
+
+	for _, tt := range tests {
+		toks, err := glox.ScanString(tt.src)
+		if err != nil {
+			t.Fatalf("scan string %q: %s", tt.src, err)
+		}
+
+		_, err = glox.NewParser(toks).Parse()
+		if err == nil {
+			t.Fatalf("expected parse of %q to fail", tt.src)
+		}
+		if got := errors.Is(err, io.ErrUnexpectedEOF); got != tt.want {
+			t.Errorf("Parse(%q) incomplete = %v but want %v: %s", tt.src, got, tt.want, err)
+		}
+	}
+}
+
+func TestParseReportsAllErrors(t *testing.T) {
+	src := `var a = 1;
+print a +;
+var = 2;
+{
+  print 1 +;
+  print a;
+}
+class A { m() { return 1 } }
+print a;
+`
+	toks, err := glox.ScanString(src)
+	if err != nil {
+		t.Fatalf("scan string: %s", err)
+	}
+
+	stmts, err := glox.NewParser(toks).Parse()
+	var errs glox.SyntaxErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected syntax errors but got: %v", err)
+	}
+
+	want := []struct {
+		pos, expected, found string
+	}{
+		{pos: "2:10", expected: "expression", found: ";"},
+		{pos: "3:5", expected: "IDENT", found: "="},
+		{pos: "5:12", expected: "expression", found: ";"},
+		{pos: "8:26", expected: ";", found: "}"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors but got %d: %s", len(want), len(errs), glox.FormatError("", []byte(src), errs))
+	}
+	for i, w := range want {
+		e := errs[i]
+		if e.Pos.String() != w.pos || e.Expected != w.expected || e.Found.Literal != w.found {
+			t.Errorf("error %d at %s expected %q found %q but want at %s expected %q found %q",
+				i, e.Pos, e.Expected, e.Found.Literal, w.pos, w.expected, w.found)
+		}
+	}
+
+	// The good parts are still there, like the block missing one statement.
+	got := glox.PrintAST(stmts[0], stmts[1], stmts[2], stmts[3])
+	wantAST := "(var a 1)\n(block (print a))\n(class A (fun m () (block)))\n(print a)"
+	if got != wantAST {
+		t.Errorf("partial AST:\n%s\nbut want\n%s", got, wantAST)
+	}
+}
+
+func TestParseRecoveryTerminates(t *testing.T) {
+	srcs := []string{
+		"}",
+		"}}}",
+		"{ } }",
+		"{ { }",
+		"class A { 1 }",
+		"class A { m( }",
+		"fun ( { ) } ;",
+		"print print print",
+		"var var var",
+		"if (if (",
+		"for (;;",
+		"a.b.c = = = ;",
+	}
+	for _, src := range srcs {
+		toks, err := glox.ScanString(src)
+		if err != nil {
+			t.Fatalf("scan string %q: %s", src, err)
+		}
+		_, err = glox.NewParser(toks).Parse()
+		if err == nil {
+			t.Errorf("expected parse of %q to fail", src)
+		}
+	}
+}
+
+func TestStreamParser(t *testing.T) {
+	src := "var a = 1;\nprint a +;\nprint a;\n/* done */"
+	p := glox.NewStreamParser(glox.NewReaderScanner("", strings.NewReader(src)))
+
+	var got []string
+	for {
+		stmt, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			got = append(got, err.Error())
+			continue
+		}
+		got = append(got, glox.PrintAST(stmt))
+	}
+	want := []string{"(var a 1)", "2:10: Expected expression", "(print a)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q but want %q", got, want)
+	}
+
+	// Scan errors come from Next too.
+	p = glox.NewStreamParser(glox.NewReaderScanner("", strings.NewReader("print 1;\nprint @;")))
+	if _, err := p.Next(); err != nil {
+		t.Fatal(err)
+	}
+	var se *glox.ScanError
+	if _, err := p.Next(); !errors.As(err, &se) || se.Code != glox.CodeUnexpectedChar {
+		t.Errorf("Next() = %v but want unexpected character", err)
+	}
+}
@@ -19,14 +19,21 @@ const (
 	classSub
 )
 
+// Resolver binds each variable to the scope it was declared in, before
+// anything runs. It also finds misuse like returning from top-level code.
 type Resolver struct {
 	i      *Interpreter
 	scopes []map[string]bool
 
 	currentFunc  funcType
 	currentClass classType
+	// loops around the current statement, in the current function.
+	loops int
+
+	errs ResolveErrors
 }
 
+// NewResolver recording bindings in i, which may be nil to only check for errors.
 func NewResolver(i *Interpreter) *Resolver {
 	return &Resolver{
 		i: i,
@@ -37,6 +44,28 @@ func NewResolver(i *Interpreter) *Resolver {
 	}
 }
 
+// Resolve stmts, returning ResolveErrors listing every problem found.
+func (r *Resolver) Resolve(stmts []Stmt) error {
+	r.errs = nil
+	for _, s := range stmts {
+		r.resolve(s)
+	}
+	if len(r.errs) > 0 {
+		return r.errs
+	}
+	return nil
+}
+
+// errorf reports a problem at token and carries on resolving.
+func (r *Resolver) errorf(at Token, code ErrorCode, format string, args ...any) {
+	r.errs = append(r.errs, &ResolveError{
+		Pos:  at.Pos(),
+		End:  at.EndPos(),
+		Code: code,
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
+
 // execute node using this AST visitor function.
 func (r *Resolver) resolve(node Node) any {
 	switch v := node.(type) {
@@ -58,8 +87,7 @@ func (r *Resolver) resolve(node Node) any {
 		if len(r.scopes) > 0 {
 			sc := r.scopes[len(r.scopes)-1]
 			if defined, ok := sc[v.name.Literal]; ok && !defined {
-				runtimeErrf("Cannot read local variable in its own initializer.")
-				return nil
+				r.errorf(v.name, CodeOwnInitializer, "Cannot read local variable in its own initializer.")
 			}
 		}
 		r.resolveLocal(v, v.name)
@@ -73,9 +101,17 @@ func (r *Resolver) resolve(node Node) any {
 		r.define(v.name)
 		r.resolveFunction(v, funcFunc)
 
+	case *FuncExpr:
+		r.resolveFunction(v.decl, funcFunc)
+
 	case *Grouping:
 		r.resolve(v.group)
 
+	case *InterpolationExpr:
+		for _, e := range v.parts {
+			r.resolve(e)
+		}
+
 	case *BinaryExpr:
 		r.resolve(v.left)
 		r.resolve(v.right)
@@ -102,20 +138,49 @@ func (r *Resolver) resolve(node Node) any {
 		r.resolve(v.object)
 		r.resolve(v.value)
 
+	case *ListExpr:
+		for _, e := range v.elems {
+			r.resolve(e)
+		}
+
+	case *MapExpr:
+		for j := range v.keys {
+			r.resolve(v.keys[j])
+			r.resolve(v.values[j])
+		}
+
+	case *IndexExpr:
+		r.resolve(v.object)
+		r.resolve(v.index)
+
+	case *SliceExpr:
+		r.resolve(v.object)
+		if v.lo != nil {
+			r.resolve(v.lo)
+		}
+		if v.hi != nil {
+			r.resolve(v.hi)
+		}
+
+	case *IndexSetExpr:
+		r.resolve(v.object)
+		r.resolve(v.index)
+		r.resolve(v.value)
+
 	case *ThisExpr:
 		if r.currentClass == classNone {
-			runtimeErrf("Can't use this outside a class.")
+			r.errorf(v.keyword, CodeThisOutsideClass, "Can't use this outside a class.")
 			return nil
 		}
 		r.resolveLocal(v, v.keyword)
 
 	case *SuperExpr:
 		if r.currentClass == classNone {
-			runtimeErrf("Can't use 'super' outside of class.")
+			r.errorf(v.keyword, CodeSuperOutsideClass, "Can't use 'super' outside of class.")
 			return nil
 		}
 		if r.currentClass != classSub {
-			runtimeErrf("Can't use 'super' in a class with no superclass.")
+			r.errorf(v.keyword, CodeSuperWithoutSuperclass, "Can't use 'super' in a class with no superclass.")
 			return nil
 		}
 		r.resolveLocal(v, v.keyword)
@@ -135,17 +200,49 @@ func (r *Resolver) resolve(node Node) any {
 
 	case *WhileStmt:
 		r.resolve(v.cond)
+		r.loops++
+		r.resolve(v.body)
+		r.loops--
+		if v.incr != nil {
+			r.resolve(v.incr)
+		}
+
+	case *BreakStmt:
+		if r.loops == 0 {
+			r.errorf(v.keyword, CodeOutsideLoop, "Can't use 'break' outside of a loop.")
+		}
+
+	case *ContinueStmt:
+		if r.loops == 0 {
+			r.errorf(v.keyword, CodeOutsideLoop, "Can't use 'continue' outside of a loop.")
+		}
+
+	case *ThrowStmt:
+		r.resolve(v.value)
+
+	case *TryStmt:
 		r.resolve(v.body)
+		if v.catch != nil {
+			// The caught error is scoped to the catch body.
+			r.beginScope()
+			r.declare(v.name)
+			r.define(v.name)
+			for _, s := range v.catch.statements {
+				r.resolve(s)
+			}
+			r.endScope()
+		}
+		if v.finally != nil {
+			r.resolve(v.finally)
+		}
 
 	case *ReturnStmt:
 		if r.currentFunc == funcNone {
-			runtimeErrf("Can't return from top-level code")
-			return nil
+			r.errorf(v.keyword, CodeTopLevelReturn, "Can't return from top-level code.")
 		}
 		if v.value != nil {
 			if r.currentFunc == funcInit {
-				runtimeErrf("Cannot return a value from initializer.")
-				return nil
+				r.errorf(v.keyword, CodeInitializerReturn, "Cannot return a value from initializer.")
 			}
 			r.resolve(v.value)
 		}
@@ -160,8 +257,7 @@ func (r *Resolver) resolve(node Node) any {
 
 		if v.super != nil {
 			if v.name.Literal == v.super.name.Literal {
-				runtimeErrf("A class can't inherit from itself.")
-				return nil
+				r.errorf(v.super.name, CodeSelfInheritance, "A class can't inherit from itself.")
 			}
 			r.currentClass = classSub // Already reset by defer.
 			r.resolve(v.super)
@@ -209,7 +305,7 @@ func (r *Resolver) declare(name Token) {
 	}
 	sc := r.scopes[len(r.scopes)-1]
 	if _, ok := sc[name.Literal]; ok {
-		runtimeErrf("Already a variable with this name in this scope")
+		r.errorf(name, CodeRedeclared, "Already a variable with this name in this scope.")
 		return
 	}
 
@@ -228,16 +324,18 @@ func (r *Resolver) resolveLocal(expr Expr, name Token) {
 	for i := len(r.scopes) - 1; i >= 0; i-- {
 		sc := r.scopes[i]
 		if _, ok := sc[name.Literal]; ok {
-			r.i.resolve(expr, len(r.scopes)-1-i)
+			if r.i != nil {
+				r.i.resolve(expr, len(r.scopes)-1-i)
+			}
 			return
 		}
 	}
 }
 
 func (r *Resolver) resolveFunction(stmt *FuncStmt, kind funcType) {
-	enclosing := r.currentFunc
-	r.currentFunc = kind
-	defer func() { r.currentFunc = enclosing }()
+	enclosing, loops := r.currentFunc, r.loops
+	r.currentFunc, r.loops = kind, 0
+	defer func() { r.currentFunc, r.loops = enclosing, loops }()
 
 	r.beginScope()
 	for _, p := range stmt.params {
@@ -0,0 +1,85 @@
+package glox_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/vikblom/glox"
+)
+
+func TestResolveReportsAllErrors(t *testing.T) {
+	src := `return 1;
+fun f() {
+  var a = 1;
+  var a = 2;
+  print this;
+}
+class A < A {
+  init() { return 1; }
+  m() { super.m(); }
+}
+{ var b = b; }
+print super.x;
+break;
+while (true) { fun g() { continue; } break; }
+`
+	toks, err := glox.ScanString(src)
+	if err != nil {
+		t.Fatalf("scan string: %s", err)
+	}
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	err = glox.NewResolver(nil).Resolve(stmts)
+	var errs glox.ResolveErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected resolve errors but got: %v", err)
+	}
+
+	want := []struct {
+		pos  string
+		code glox.ErrorCode
+	}{
+		{pos: "1:1", code: glox.CodeTopLevelReturn},
+		{pos: "4:7", code: glox.CodeRedeclared},
+		{pos: "5:9", code: glox.CodeThisOutsideClass},
+		{pos: "7:11", code: glox.CodeSelfInheritance},
+		{pos: "8:12", code: glox.CodeInitializerReturn},
+		{pos: "11:11", code: glox.CodeOwnInitializer},
+		{pos: "12:7", code: glox.CodeSuperOutsideClass},
+		{pos: "13:1", code: glox.CodeOutsideLoop},
+		{pos: "14:26", code: glox.CodeOutsideLoop},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors but got %d:\n%s", len(want), len(errs), glox.FormatError("", []byte(src), errs))
+	}
+	for i, w := range want {
+		if got := errs[i]; got.Pos.String() != w.pos || got.Code != w.code {
+			t.Errorf("error %d is %s at %s but want %s at %s", i, got.Code, got.Pos, w.code, w.pos)
+		}
+	}
+}
+
+func TestResolveBeforeRunning(t *testing.T) {
+	toks, err := glox.ScanString(`print "side effect"; return;`)
+	if err != nil {
+		t.Fatalf("scan string: %s", err)
+	}
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = glox.NewInterpreter(buf).Interpret(stmts)
+	var re *glox.ResolveError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected resolve error but got: %v", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("expected nothing to run but got output: %q", buf.String())
+	}
+}
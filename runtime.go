@@ -3,20 +3,224 @@ package glox
 import (
 	"fmt"
 	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type runtimeError struct{ error }
-
-func runtimeErrf(format string, args ...any) {
-	panic(runtimeError{error: fmt.Errorf("RUNTIME ERROR: "+format, args...)})
+func runtimeErrf(at Token, code ErrorCode, format string, args ...any) {
+	panic(&RuntimeError{
+		Pos:  at.Pos(),
+		End:  at.EndPos(),
+		Code: code,
+		Msg:  fmt.Sprintf(format, args...),
+	})
 }
 
 func mustBeNumbers(tok Token, args ...any) {
 	for _, o := range args {
-		if _, ok := o.(float64); !ok {
-			runtimeErrf("%q requires number arguments: %T", tok.Literal, o)
+		if !isNumber(o) {
+			runtimeErrf(tok, CodeOperandType, "%q requires number arguments: %s", tok.Literal, typeName(o))
+		}
+	}
+}
+
+// typeName of v as a Lox programmer knows it.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case *LoxClass, *goClass:
+		return "class"
+	case *LoxInstance, *goInstance:
+		return "instance"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case callable:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Stringify v the way print shows it.
+func Stringify(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// inspect v like Stringify, but quote strings as they are within a list.
+// Lists and maps already in seen show as [...] and {...},
+// so a list can contain itself.
+func inspect(v any, seen map[any]bool) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case *LoxList:
+		if seen[v] {
+			return "[...]"
 		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		var sb strings.Builder
+		sb.WriteString("[")
+		for i, e := range v.elems {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(inspect(e, seen))
+		}
+		sb.WriteString("]")
+		return sb.String()
+	case *LoxMap:
+		if seen[v] {
+			return "{...}"
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		var sb strings.Builder
+		sb.WriteString("{")
+		for i := range v.keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(inspect(v.keys[i], seen))
+			sb.WriteString(": ")
+			sb.WriteString(inspect(v.values[i], seen))
+		}
+		sb.WriteString("}")
+		return sb.String()
+	}
+	return Stringify(v)
+}
+
+// formatFloat like 0.5, 3.0 or 1e+21, always looking like a float.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	var s string
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		s = strconv.FormatFloat(f, 'e', -1, 64)
+	} else {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+// toFloat promotes number v to a float.
+func toFloat(v any) float64 {
+	if n, ok := v.(int64); ok {
+		return float64(n)
+	}
+	return v.(float64)
+}
+
+// arith applies op to numbers l and r. Two ints give an int, wrapping
+// around on overflow. Anything else is promoted to a float.
+func arith(op Token, l, r any) any {
+	mustBeNumbers(op, l, r)
+	if a, ok := l.(int64); ok {
+		if b, ok := r.(int64); ok {
+			switch op.Kind {
+			case PLUS:
+				return a + b
+			case DASH:
+				return a - b
+			case STAR:
+				return a * b
+			case SLASH, PERCENT:
+				if b == 0 {
+					runtimeErrf(op, CodeDivisionByZero, "Integer division by zero.")
+				}
+				if op.Kind == SLASH {
+					return a / b
+				}
+				return a % b
+			}
+		}
+	}
+
+	a, b := toFloat(l), toFloat(r)
+	switch op.Kind {
+	case PLUS:
+		return a + b
+	case DASH:
+		return a - b
+	case STAR:
+		return a * b
+	case SLASH:
+		return a / b
+	case PERCENT:
+		return math.Mod(a, b)
+	}
+	runtimeErrf(op, CodeInternal, "impossible arithmetic")
+	return nil
+}
+
+// compare numbers l and r with op.
+func compare(op Token, l, r any) bool {
+	mustBeNumbers(op, l, r)
+	if a, ok := l.(int64); ok {
+		if b, ok := r.(int64); ok {
+			switch op.Kind {
+			case GREATER:
+				return a > b
+			case GREATER_EQUAL:
+				return a >= b
+			case LESS:
+				return a < b
+			case LESS_EQUAL:
+				return a <= b
+			}
+		}
+	}
+
+	a, b := toFloat(l), toFloat(r)
+	switch op.Kind {
+	case GREATER:
+		return a > b
+	case GREATER_EQUAL:
+		return a >= b
+	case LESS:
+		return a < b
+	case LESS_EQUAL:
+		return a <= b
 	}
+	runtimeErrf(op, CodeInternal, "impossible comparison")
+	return false
 }
 
 type Env struct {
@@ -44,30 +248,30 @@ func (e *Env) define(name string, val any) {
 	e.vars[name] = val
 }
 
-func (e *Env) assign(name string, val any) {
-	if _, ok := e.vars[name]; ok {
-		e.vars[name] = val
+func (e *Env) assign(name Token, val any) {
+	if _, ok := e.vars[name.Literal]; ok {
+		e.vars[name.Literal] = val
 		return
 	}
 	if e.enclosing != nil {
 		e.enclosing.assign(name, val)
 		return
 	}
-	runtimeErrf("undefined %q", name)
+	runtimeErrf(name, CodeUndefinedVariable, "Undefined variable %q.", name.Literal)
 }
 
 func (i *Interpreter) lookupVariable(name Token, expr Expr) any {
 	distance, ok := i.locals[expr]
 	if !ok {
-		return i.global.get(name.Literal)
+		return i.global.get(name)
 	}
-	return i.scope.up(distance).get(name.Literal)
+	return i.scope.up(distance).get(name)
 }
 
-func (e *Env) get(name string) any {
-	v, ok := e.vars[name]
+func (e *Env) get(name Token) any {
+	v, ok := e.vars[name.Literal]
 	if !ok {
-		runtimeErrf("undefined %q", name)
+		runtimeErrf(name, CodeUndefinedVariable, "Undefined variable %q.", name.Literal)
 		return nil
 	}
 	return v
@@ -81,8 +285,26 @@ func (e *Env) up(distance int) *Env {
 	return env
 }
 
-// returnValue by panic...
-type returnValue struct{ any }
+// completion of a statement, telling enclosing statements how to go on.
+// A runtime error in an expression panics instead, as does a throwing
+// completion where it reaches a call.
+type completion struct {
+	kind completionKind
+	// value returned.
+	value any
+	// err thrown.
+	err *RuntimeError
+}
+
+type completionKind int
+
+const (
+	normal completionKind = iota
+	returning
+	breaking
+	continuing
+	throwing
+)
 
 type Interpreter struct {
 	out    io.Writer
@@ -91,70 +313,198 @@ type Interpreter struct {
 
 	// Static analysis.
 	locals map[Expr]int
+
+	// calls in progress, outermost first.
+	calls []call
+
+	// errorClass is the Error of the prelude, caught runtime errors are instances.
+	errorClass *LoxClass
+	// goClasses bound to Go struct types.
+	goClasses map[reflect.Type]*goClass
 }
 
+// call in progress, kept for stack traces.
+type call struct {
+	class    string
+	function string
+	// site the function was called from.
+	site Token
+}
+
+// prelude is Lox code defined before any script runs.
+const prelude = `
+class Error {
+  init(message) {
+    this.message = message;
+    this.stack = nil; // Set when thrown.
+  }
+}
+`
+
 func NewInterpreter(out io.Writer) *Interpreter {
 	g := NewEnv()
-	g.define("clock", &builtinClock{})
-
-	return &Interpreter{
+	i := &Interpreter{
 		out: out,
 		// Fixed ref to top level scope.
 		global: g,
 		// Current scope, will change as we execute.
 		scope: g,
 
-		locals: map[Expr]int{},
+		locals:    map[Expr]int{},
+		goClasses: map[reflect.Type]*goClass{},
+	}
+
+	toks, err := ScanFile("<prelude>", []byte(prelude))
+	if err != nil {
+		panic(fmt.Sprintf("scan prelude: %s", err))
+	}
+	stmts, err := NewParser(toks).Parse()
+	if err != nil {
+		panic(fmt.Sprintf("parse prelude: %s", err))
+	}
+	if err := i.Interpret(stmts); err != nil {
+		panic(fmt.Sprintf("interpret prelude: %s", err))
+	}
+	i.errorClass = g.vars["Error"].(*LoxClass)
+
+	i.RegisterFunc("clock", 0, func(_ []Value) (Value, error) {
+		return time.Now().Unix(), nil
+	})
+
+	return i
+}
+
+// RegisterFunc fn as a global function called name, taking arity arguments
+// or any number of them if arity is Variadic. Arguments are Lox values, and
+// Go numbers returned become Lox numbers like for VM.SetGlobal. An error
+// from fn is a runtime error where Lox called it, which unwraps to the error,
+// and so is returning a Go value without a Lox value.
+func (i *Interpreter) RegisterFunc(name string, arity int, fn Func) {
+	i.global.define(name, &nativeFunc{name: name, params: arity, fn: fn})
+}
+
+// Globals defined in i, by name.
+func (i *Interpreter) Globals() map[string]any {
+	vars := make(map[string]any, len(i.global.vars))
+	for k, v := range i.global.vars {
+		vars[k] = v
 	}
+	return vars
 }
 
 func (i *Interpreter) resolve(expr Expr, depth int) {
 	i.locals[expr] = depth
 }
 
-func (i *Interpreter) Interpret(stmts []Stmt) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if re, ok := r.(runtimeError); ok {
-				err = re.error
-			} else {
-				panic(r)
-			}
-		}
-	}()
+func (i *Interpreter) Interpret(stmts []Stmt) error {
+	_, err := i.Eval(stmts)
+	return err
+}
 
-	// Statically analyze variable decl/define.
-	// TODO: Move somewhere outside?
-	r := NewResolver(i)
-	for _, s := range stmts {
-		r.resolve(s)
+// Eval stmts like Interpret, returning the value of the last statement
+// if it is an expression statement. Handy for a REPL to echo results.
+func (i *Interpreter) Eval(stmts []Stmt) (v any, err error) {
+	defer i.recoverError(&err, len(i.calls), i.scope)
+
+	// Statically analyze variable decl/define, so nothing runs on errors.
+	if err := NewResolver(i).Resolve(stmts); err != nil {
+		return nil, err
 	}
 
 	for _, s := range stmts {
-		_, err := i.EvalAST(s)
-		if err != nil {
-			return fmt.Errorf("runtime error: %w", err)
+		v = nil
+		if es, ok := s.(*ExprStmt); ok {
+			v = i.evaluate(es.expr)
+			continue
+		}
+		// The resolver leaves throwing as the only way out of top-level code.
+		if c := i.execute(s); c.kind == throwing {
+			return nil, c.err
 		}
 	}
-	return nil
+	return v, nil
+}
+
+// recoverError panicking out of the interpreter into err,
+// unwinding calls and scopes to what they were when it was deferred.
+// That keeps the calls further up the stack when a native function calls
+// back into Lox. It must be deferred to recover.
+func (i *Interpreter) recoverError(err *error, depth int, scope *Env) {
+	if r := recover(); r != nil {
+		switch e := r.(type) {
+		case *RuntimeError:
+			if e.Stack == nil {
+				e.Stack = i.stack(e.Pos)
+			}
+			i.calls, i.scope = i.calls[:depth], scope
+			*err = e
+		default:
+			panic(r)
+		}
+	}
+}
+
+// callValue callee with args from site, if it is callable with that many args.
+func (i *Interpreter) callValue(site Token, callee any, args []any) any {
+	callable, ok := callee.(callable)
+	if !ok {
+		runtimeErrf(site, CodeNotCallable, "Not callable %s", typeName(callee))
+		return nil
+	}
+	if n := callable.arity(); n != Variadic && n != len(args) {
+		runtimeErrf(site, CodeArity, "Expected %d arguments but got %d", n, len(args))
+		return nil
+	}
+	class, function := frameName(callable)
+	i.calls = append(i.calls, call{class: class, function: function, site: site})
+	ret := callable.call(i, args)
+	i.calls = i.calls[:len(i.calls)-1]
+	return ret
+}
+
+// stack of frames where pos is in the innermost call.
+func (i *Interpreter) stack(pos Position) []Frame {
+	frames := make([]Frame, 0, len(i.calls)+1)
+	for j := len(i.calls) - 1; j >= 0; j-- {
+		c := i.calls[j]
+		frames = append(frames, Frame{Function: c.function, Class: c.class, Pos: pos})
+		pos = i.calls[j].site.Pos()
+	}
+	// Calls from Go have no site, and no script below them.
+	if pos.Line == 0 {
+		return frames
+	}
+	return append(frames, Frame{Function: "<script>", Pos: pos})
 }
 
 // EvalAST rooted at node.
-// There are 4 types used for values: any, string, float64 & bool.
+// There are 5 types used for values: any, string, int64, float64 & bool.
 func (i *Interpreter) EvalAST(node Node) (v any, err error) {
-	v = node.Accept(i.execute)
+	switch n := node.(type) {
+	case Expr:
+		v = i.evaluate(n)
+	case Stmt:
+		i.execute(n)
+	}
 	return
 }
 
-// execute node using this AST visitor function.
-func (i *Interpreter) execute(node Node) any {
-	switch v := node.(type) {
+// evaluate expr using this AST visitor function.
+func (i *Interpreter) evaluate(expr Expr) any {
+	switch v := expr.(type) {
 	case *Grouping:
-		return i.execute(v.group)
+		return i.evaluate(v.group)
+
+	case *InterpolationExpr:
+		var sb strings.Builder
+		for _, e := range v.parts {
+			sb.WriteString(Stringify(i.evaluate(e)))
+		}
+		return sb.String()
 
 	case *BinaryExpr:
-		l := i.execute(v.left)
-		r := i.execute(v.right)
+		l := i.evaluate(v.left)
+		r := i.evaluate(v.right)
 		switch v.op.Kind {
 		case EQUAL_EQUAL:
 			return isEqual(l, r)
@@ -164,35 +514,26 @@ func (i *Interpreter) execute(node Node) any {
 
 		switch v.op.Kind {
 		case PLUS:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) + r.(float64)
-		case DASH:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) - r.(float64)
-		case STAR:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) * r.(float64)
-		case SLASH:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) / r.(float64)
-
-		case GREATER:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) > r.(float64)
-		case GREATER_EQUAL:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) >= r.(float64)
-		case LESS:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) < r.(float64)
-		case LESS_EQUAL:
-			mustBeNumbers(v.op, l, r)
-			return l.(float64) <= r.(float64)
-		}
-		runtimeErrf("impossible binary")
+			// Add numbers or concatenate strings, but never convert
+			// implicitly, so "a" + 1 is an error.
+			if isNumber(l) && isNumber(r) {
+				return arith(v.op, l, r)
+			}
+			if l, ok := l.(string); ok {
+				if r, ok := r.(string); ok {
+					return l + r
+				}
+			}
+			runtimeErrf(v.op, CodeOperandType, "Operands of \"+\" must be two numbers or two strings: %s and %s", typeName(l), typeName(r))
+		case DASH, STAR, SLASH, PERCENT:
+			return arith(v.op, l, r)
+		case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+			return compare(v.op, l, r)
+		}
+		runtimeErrf(v.op, CodeInternal, "impossible binary")
 
 	case *LogicalExpr:
-		left := i.execute(v.left)
+		left := i.evaluate(v.left)
 
 		// The value of left can short circuit the expression.
 		switch v.op.Kind {
@@ -208,21 +549,24 @@ func (i *Interpreter) execute(node Node) any {
 			panic("impossible logical")
 		}
 
-		return i.execute(v.right)
+		return i.evaluate(v.right)
 
 	case *UnaryExpr:
 		switch v.op.Kind {
 		case DASH:
-			r := i.execute(v.right)
+			r := i.evaluate(v.right)
 			mustBeNumbers(v.op, r)
-			if f, ok := r.(float64); ok {
-				return -f
+			switch n := r.(type) {
+			case int64:
+				return -n
+			case float64:
+				return -n
 			}
 		case BANG:
-			vv := i.execute(v.right)
+			vv := i.evaluate(v.right)
 			return !isTruthy(vv)
 		}
-		runtimeErrf("impossible unary")
+		runtimeErrf(v.op, CodeInternal, "impossible unary")
 
 	case *Literal:
 		return v.val
@@ -231,87 +575,156 @@ func (i *Interpreter) execute(node Node) any {
 		return i.lookupVariable(v.name, v)
 
 	case *Assign:
-		val := i.execute(v.val)
+		val := i.evaluate(v.val)
 
 		dist, ok := i.locals[v] // FIXME: Must this be the Expr?
 		if !ok {
-			i.global.assign(v.name.Literal, val)
+			i.global.assign(v.name, val)
 		} else {
-			i.scope.up(dist).assign(v.name.Literal, val)
+			i.scope.up(dist).assign(v.name, val)
 		}
 
 		return val
 
 	case *Call:
-		callee := i.execute(v.callee)
+		callee := i.evaluate(v.callee)
 
 		args := []any{}
 		for _, a := range v.args {
-			args = append(args, i.execute(a))
+			args = append(args, i.evaluate(a))
 		}
 
-		callable, ok := callee.(callable)
-		if !ok {
-			runtimeErrf("Not callable %T", callee)
-			return nil
-		}
-		if callable.arity() != len(args) {
-			runtimeErrf("Expected %d arguments but got %d", callable.arity(), len(args))
-			return nil
-		}
-		return callable.call(i, args)
+		return i.callValue(v.paren, callee, args)
 
 	case *GetExpr:
-		obj := i.execute(v.object)
-		inst, ok := obj.(*LoxInstance)
+		obj := i.evaluate(v.object)
+		o, ok := obj.(object)
 		if !ok {
-			runtimeErrf("Object %T does not have properties, must be instance.", obj)
+			runtimeErrf(v.name, CodeNotInstance, "Object %s does not have properties, must be instance.", typeName(obj))
 			return nil
 		}
-		return inst.get(v.name.Literal)
+		return o.get(v.name)
 
 	case *SetExpr:
-		obj := i.execute(v.object)
+		obj := i.evaluate(v.object)
+
+		switch inst := obj.(type) {
+		case *LoxInstance:
+			val := i.evaluate(v.value)
+			inst.set(v.name.Literal, val)
+			return val
+		case *goInstance:
+			val := i.evaluate(v.value)
+			inst.set(v.name, val)
+			return val
+		}
+		runtimeErrf(v.name, CodeNotInstance, "Object %s does not have fields, must be instance.", typeName(obj))
+		return nil
 
-		inst, ok := obj.(*LoxInstance)
+	case *ListExpr:
+		elems := make([]any, 0, len(v.elems))
+		for _, e := range v.elems {
+			elems = append(elems, i.evaluate(e))
+		}
+		return &LoxList{elems: elems}
+
+	case *MapExpr:
+		m := newMap()
+		for j := range v.keys {
+			m.set(i.evaluate(v.keys[j]), i.evaluate(v.values[j]))
+		}
+		return m
+
+	case *IndexExpr:
+		obj := i.evaluate(v.object)
+		index := i.evaluate(v.index)
+		switch obj := obj.(type) {
+		case *LoxList:
+			return obj.elems[obj.index(v.lbracket, index, len(obj.elems))]
+		case *LoxMap:
+			val, ok := obj.lookup(index)
+			if !ok {
+				runtimeErrf(v.lbracket, CodeUndefinedKey, "Undefined key %s.", inspect(index, map[any]bool{}))
+			}
+			return val
+		}
+		runtimeErrf(v.lbracket, CodeNotIndexable, "Can't index %s.", typeName(obj))
+		return nil
+
+	case *SliceExpr:
+		obj := i.evaluate(v.object)
+		var lo, hi any
+		if v.lo != nil {
+			lo = i.evaluate(v.lo)
+		}
+		if v.hi != nil {
+			hi = i.evaluate(v.hi)
+		}
+		list, ok := obj.(*LoxList)
 		if !ok {
-			runtimeErrf("Object %T does not have fields, must be instance.", obj)
+			runtimeErrf(v.lbracket, CodeNotIndexable, "Can't slice %s.", typeName(obj))
 			return nil
 		}
-		val := i.execute(v.value)
-		inst.set(v.name.Literal, val)
-		return val
+		return list.slice(v.lbracket, lo, hi)
+
+	case *IndexSetExpr:
+		obj := i.evaluate(v.object)
+		index := i.evaluate(v.index)
+		val := i.evaluate(v.value)
+		switch obj := obj.(type) {
+		case *LoxList:
+			obj.elems[obj.index(v.lbracket, index, len(obj.elems))] = val
+			return val
+		case *LoxMap:
+			obj.set(index, val)
+			return val
+		}
+		runtimeErrf(v.lbracket, CodeNotIndexable, "Can't index %s.", typeName(obj))
+		return nil
 
 	case *ThisExpr:
 		return i.lookupVariable(v.keyword, v)
 
 	case *SuperExpr:
 		dist := i.locals[v]
-		super, ok := i.scope.up(dist).get("super").(*LoxClass)
+		super, ok := i.scope.up(dist).get(v.keyword).(*LoxClass)
 		if !ok {
-			runtimeErrf("not a class")
+			runtimeErrf(v.keyword, CodeInternal, "not a class")
 			return nil
 		}
 		// We know the instance is just before where super is hooked on.
-		obj, ok := i.scope.up(dist - 1).get("this").(*LoxInstance)
+		obj, ok := i.scope.up(dist - 1).vars["this"].(*LoxInstance)
 		if !ok {
-			runtimeErrf("not an instance")
+			runtimeErrf(v.keyword, CodeInternal, "not an instance")
 			return nil
 		}
 		method := super.findMethod(v.method.Literal)
 		if method == nil {
-			runtimeErrf("Undefined property %q", v.method.Literal)
+			runtimeErrf(v.method, CodeUndefinedProperty, "Undefined property %q", v.method.Literal)
 		}
 		return method.bind(obj)
 
+	case *FuncExpr:
+		return &LoxFunction{decl: v.decl, closure: i.scope}
+
+	default:
+		panic(fmt.Sprintf("unknown expression: %T :: %#v", expr, expr))
+	}
+
+	panic("unreachable")
+}
+
+// execute stmt, returning how it completed.
+func (i *Interpreter) execute(stmt Stmt) completion {
+	switch v := stmt.(type) {
 	case *PrintStmt:
-		val := i.execute(v.expr)
-		fmt.Fprintf(i.out, "%v\n", val)
-		return nil
+		val := i.evaluate(v.expr)
+		fmt.Fprintln(i.out, Stringify(val))
+		return completion{}
 
 	case *ExprStmt:
-		_ = i.execute(v.expr)
-		return nil
+		_ = i.evaluate(v.expr)
+		return completion{}
 
 	case *FuncStmt:
 		fn := &LoxFunction{
@@ -320,98 +733,202 @@ func (i *Interpreter) execute(node Node) any {
 			isInitializer: false,
 		}
 		i.scope.define(v.name.Literal, fn)
-		return nil
+		return completion{}
 
 	case *VarStmt:
 		var val any
 		if v.init != nil {
-			val = i.execute(v.init)
+			val = i.evaluate(v.init)
 		}
 		i.scope.define(v.name.Literal, val)
-		return nil
+		return completion{}
 
 	case *BlockStmt:
-		i.executeBlock(v.statements, i.scope.Fork())
-		return nil
+		return i.executeBlock(v.statements, i.scope.Fork())
 
 	case *IfStmt:
-		if isTruthy(i.execute(v.cond)) {
-			i.execute(v.thenBranch)
+		if isTruthy(i.evaluate(v.cond)) {
+			return i.execute(v.thenBranch)
 		} else if v.elseBranch != nil {
-			i.execute(v.elseBranch)
+			return i.execute(v.elseBranch)
 		}
-		return nil
+		return completion{}
 
 	case *WhileStmt:
-		for isTruthy(i.execute(v.cond)) {
-			i.execute(v.body)
+		for isTruthy(i.evaluate(v.cond)) {
+			switch c := i.execute(v.body); c.kind {
+			case breaking:
+				return completion{}
+			case returning, throwing:
+				return c
+			}
+			if v.incr != nil {
+				i.evaluate(v.incr)
+			}
 		}
-		return nil
+		return completion{}
+
+	case *BreakStmt:
+		return completion{kind: breaking}
+
+	case *ContinueStmt:
+		return completion{kind: continuing}
 
 	case *ReturnStmt:
 		var value any
 		if v.value != nil {
-			value = i.execute(v.value)
+			value = i.evaluate(v.value)
+		}
+		return completion{kind: returning, value: value}
+
+	case *ThrowStmt:
+		val := i.evaluate(v.value)
+		err := &RuntimeError{
+			Pos:    v.keyword.Pos(),
+			End:    v.semicolon.EndPos(),
+			Code:   CodeThrown,
+			thrown: true,
+			value:  val,
+		}
+		err.Stack = i.stack(err.Pos)
+		if inst, ok := val.(*LoxInstance); ok && inst.class.isSubclass(i.errorClass) {
+			if inst.fields["stack"] == nil {
+				inst.fields["stack"] = stackList(err.Stack)
+			}
+			err.Msg = Stringify(inst.fields["message"])
+		} else {
+			err.Msg = "Uncaught " + inspect(val, map[any]bool{})
 		}
-		panic(returnValue{value})
+		return completion{kind: throwing, err: err}
+
+	case *TryStmt:
+		return i.executeTry(v)
 
 	case *ClassStmt:
 		var super *LoxClass
 		if v.super != nil {
-			inherited, ok := i.execute(v.super).(*LoxClass)
+			inherited, ok := i.evaluate(v.super).(*LoxClass)
 			if !ok {
-				runtimeErrf("Superclass must be a class.")
-				return nil
+				runtimeErrf(v.super.name, CodeSuperclassType, "Superclass must be a class.")
+				return completion{}
 			}
 			super = inherited
 		}
 
 		i.scope.define(v.name.Literal, nil)
 
+		enclosing := i.scope
 		if super != nil {
 			i.scope = i.scope.Fork()
 			i.scope.define("super", super)
-			defer func() { i.scope = i.scope.enclosing }()
 		}
 
 		methods := map[string]*LoxFunction{}
 		for _, m := range v.methods {
 			fun, ok := m.(*FuncStmt)
 			if !ok {
-				runtimeErrf("not a method")
+				runtimeErrf(v.name, CodeInternal, "not a method")
 			}
 			methods[fun.name.Literal] = &LoxFunction{
 				decl:          fun,
 				closure:       i.scope,
 				isInitializer: fun.name.Literal == "init",
+				class:         v.name.Literal,
 			}
 		}
+		i.scope = enclosing
 
 		class := &LoxClass{
 			name:    v.name.Literal,
 			methods: methods,
 			super:   super,
 		}
-		i.scope.assign(v.name.Literal, class)
-		return nil
+		i.scope.assign(v.name, class)
+		return completion{}
 
 	default:
-		panic(fmt.Sprintf("unknown node: %T :: %#v", node, node))
+		panic(fmt.Sprintf("unknown statement: %T :: %#v", stmt, stmt))
 	}
-
-	panic("unreachable")
 }
 
 // executeBlock in the given env.
 // Used when entering a block, function etc.
-func (i *Interpreter) executeBlock(statements []Stmt, env *Env) {
+// It stops at the first statement which does not complete normally.
+func (i *Interpreter) executeBlock(statements []Stmt, env *Env) completion {
+	// A runtime error panics past this, whoever recovers restores the scope.
 	prev := i.scope
-	defer func() { i.scope = prev }()
-
 	i.scope = env
 	for _, s := range statements {
-		i.execute(s)
+		if c := i.execute(s); c.kind != normal {
+			i.scope = prev
+			return c
+		}
 	}
+	i.scope = prev
+	return completion{}
+}
+
+// executeTry runs the catch if the body throws, and the finally no matter
+// how the body and catch complete.
+func (i *Interpreter) executeTry(v *TryStmt) completion {
+	c := i.executeProtected(v.body.statements, i.scope.Fork())
+	if c.kind == throwing && v.catch != nil {
+		env := i.scope.Fork()
+		env.define(v.name.Literal, i.caught(c.err))
+		c = i.executeProtected(v.catch.statements, env)
+	}
+	if v.finally != nil {
+		// Unless the finally itself returns, breaks etc,
+		// the try completes like its body or catch did.
+		if f := i.executeBlock(v.finally.statements, i.scope.Fork()); f.kind != normal {
+			return f
+		}
+	}
+	return c
+}
+
+// executeProtected executes like executeBlock, but completes by throwing
+// instead of panicking on runtime errors.
+func (i *Interpreter) executeProtected(statements []Stmt, env *Env) (c completion) {
+	depth, scope := len(i.calls), i.scope
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*RuntimeError)
+			if !ok || e.Code == CodeInternal {
+				panic(r)
+			}
+			if e.Stack == nil {
+				e.Stack = i.stack(e.Pos)
+			}
+			// Unwind calls and scopes the panic skipped.
+			i.calls, i.scope = i.calls[:depth], scope
+			c = completion{kind: throwing, err: e}
+		}
+	}()
+	return i.executeBlock(statements, env)
+}
+
+// caught value of e for a catch, what was thrown or an Error for runtime errors.
+func (i *Interpreter) caught(e *RuntimeError) any {
+	if e.thrown {
+		return e.value
+	}
+	return &LoxInstance{
+		class: i.errorClass,
+		fields: map[string]any{
+			"message": e.Msg,
+			"stack":   stackList(e.Stack),
+		},
+	}
+}
+
+// stackList of frames like "at f (script.lox:12)", innermost first.
+func stackList(frames []Frame) *LoxList {
+	elems := make([]any, len(frames))
+	for j, f := range frames {
+		elems[j] = f.String()
+	}
+	return &LoxList{elems: elems}
 }
 
 func isEqual(a, b any) bool {
@@ -421,6 +938,17 @@ func isEqual(a, b any) bool {
 	if a == nil {
 		return false
 	}
+	// Numbers are equal by value, whether ints or floats. An int equals a
+	// float only if the float is exactly that int, so == agrees with map keys.
+	if isNumber(a) && isNumber(b) {
+		return mapKey(a) == mapKey(b)
+	}
+	// Proxies are equal if they are of the same Go value.
+	if ga, ok := a.(*goInstance); ok {
+		if gb, ok := b.(*goInstance); ok {
+			return ga.key() == gb.key()
+		}
+	}
 	return a == b // Does this work on interfaces?
 }
 
@@ -27,9 +27,13 @@ func TestTestdata(t *testing.T) {
 			if err != nil {
 				t.Fatalf("txtar parse: %s", err)
 			}
-			if len(a.Files) != 2 || (a.Files[0].Name != "src.lox") || (a.Files[1].Name != "stdout") {
+			// An optional third file has the error interpreting should end with.
+			if len(a.Files) < 2 || len(a.Files) > 3 || (a.Files[0].Name != "src.lox") || (a.Files[1].Name != "stdout") {
 				t.Fatalf("%s: want two files named \"src.lox\" & \"stdout\"", file)
 			}
+			if len(a.Files) == 3 && a.Files[2].Name != "error" {
+				t.Fatalf("%s: want third file named \"error\"", file)
+			}
 
 			src := a.Files[0].Data
 			toks, err := glox.ScanBytes(src)
@@ -46,13 +50,18 @@ func TestTestdata(t *testing.T) {
 			buf := bytes.NewBuffer(nil)
 			i := glox.NewInterpreter(buf)
 			err = i.Interpret(stmts)
+			got := buf.String()
+			gotErr := ""
 			if err != nil {
-				t.Fatalf("interpret: %s", err)
+				gotErr = err.Error() + "\n"
 			}
-			got := buf.String()
 
 			if *updateGolden {
+				a.Files = a.Files[:2]
 				a.Files[1].Data = buf.Bytes()
+				if err != nil {
+					a.Files = append(a.Files, txtar.File{Name: "error", Data: []byte(gotErr)})
+				}
 				bs := txtar.Format(a)
 				os.WriteFile(file, bs, 0644)
 				return
@@ -62,6 +71,14 @@ func TestTestdata(t *testing.T) {
 			if d := cmp.Diff(want, got); d != "" {
 				t.Fatalf("interpreted stdout diff (-want, +got):\n%s", d)
 			}
+
+			wantErr := ""
+			if len(a.Files) == 3 {
+				wantErr = string(a.Files[2].Data)
+			}
+			if d := cmp.Diff(wantErr, gotErr); d != "" {
+				t.Fatalf("interpret error diff (-want, +got):\n%s", d)
+			}
 		})
 	}
 }
@@ -69,12 +86,28 @@ func TestTestdata(t *testing.T) {
 func TestEvalArithmetic(t *testing.T) {
 	tests := []struct {
 		src  string
-		want float64
+		want any
 	}{
-		{src: "1;", want: 1.0},
-		{src: "1 + 1;", want: 2.0},
-		{src: "1 + (2 - 3);", want: 0.0},
-		{src: "-1 * -1;", want: 1.0},
+		{src: "1;", want: int64(1)},
+		{src: "1 + 1;", want: int64(2)},
+		{src: "1 + (2 - 3);", want: int64(0)},
+		{src: "-1 * -1;", want: int64(1)},
+		{src: "7 / 2;", want: int64(3)},
+		{src: "-7 / 2;", want: int64(-3)},
+		{src: "7 % 3;", want: int64(1)},
+		{src: "-7 % 3;", want: int64(-1)},
+		{src: "0xFF + 0b1010;", want: int64(265)},
+		{src: "1_000_000;", want: int64(1000000)},
+		{src: "9223372036854775807 + 1;", want: int64(-9223372036854775808)},
+
+		// Floats, and ints promoted to floats.
+		{src: "1.5;", want: 1.5},
+		{src: "1e3;", want: 1000.0},
+		{src: "2.5e-3;", want: 0.0025},
+		{src: "7.0 / 2;", want: 3.5},
+		{src: "1 + 0.5;", want: 1.5},
+		{src: "7.5 % 2;", want: 1.5},
+		{src: "-0.5;", want: -0.5},
 	}
 
 	for _, tt := range tests {
@@ -94,12 +127,8 @@ func TestEvalArithmetic(t *testing.T) {
 		if err != nil {
 			t.Fatalf("eval ast: %s", err)
 		}
-		got, ok := v.(float64)
-		if !ok {
-			t.Fatalf("expected float64 but got %T :: %v", v, v)
-		}
-		if tt.want != got {
-			t.Fatalf(`PrintAst("%s") = %f but want %f`, tt.src, got, tt.want)
+		if tt.want != v {
+			t.Fatalf(`EvalAST("%s") = %T %v but want %T %v`, tt.src, v, v, tt.want, tt.want)
 		}
 	}
 }
@@ -118,6 +147,17 @@ func TestEvalComparison(t *testing.T) {
 		{src: "1 >= 3;", want: false},
 		{src: "!false;", want: true},
 		{src: "!(false) == true;", want: true},
+
+		// Ints and floats compare by value.
+		{src: "1 == 1.0;", want: true},
+		{src: "1 != 1.5;", want: true},
+		{src: "1 < 1.5;", want: true},
+		{src: "9007199254740993 > 9007199254740992;", want: true},
+		// But only equal if the float is exactly the int.
+		{src: "9007199254740993 == 9007199254740992.0;", want: false},
+		{src: "9007199254740992 == 9007199254740992.0;", want: true},
+		{src: "9223372036854775807 == 9223372036854775807.0;", want: false},
+		{src: "0.5 == 0.5;", want: true},
 	}
 
 	for _, tt := range tests {
@@ -154,11 +194,19 @@ func TestEvalPrints(t *testing.T) {
 	}{
 		{src: "var a; print a;", want: "<nil>\n"},
 		{src: "var a = 1; print a;", want: "1\n"},
-		{src: `var hello = 1; print "hello";`, want: "\"hello\"\n"},
+		{src: `var hello = 1; print "hello";`, want: "hello\n"},
+		{src: `print "foo" + "bar";`, want: "foobar\n"},
 		{src: `var a = 1; var b = 2; print a + b;`, want: "3\n"},
 		{src: `var a = 1; a = 2; print a;`, want: "2\n"},
 		// Assignment is an expression.
 		{src: `var a = 1; print a = 123;`, want: "123\n"},
+		// Floats always look like floats.
+		{src: `print 3.0;`, want: "3.0\n"},
+		{src: `print 1 / 2.0;`, want: "0.5\n"},
+		{src: `print 1e6;`, want: "1000000.0\n"},
+		{src: `print 1e21;`, want: "1e+21\n"},
+		{src: `print 0.00001;`, want: "1e-05\n"},
+		{src: `print -1 / 0.0;`, want: "-inf\n"},
 	}
 
 	for _, tt := range tests {
@@ -186,3 +234,115 @@ func TestEvalPrints(t *testing.T) {
 		}
 	}
 }
+
+func TestEvalKeepsState(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{src: "var a = 1;", want: nil},
+		{src: "a + 1;", want: int64(2)},
+		{src: "fun inc() { a = a + 1; return a; }", want: nil},
+		{src: "inc();", want: int64(2)},
+		{src: "inc(); print a;", want: nil},
+		{src: "a;", want: int64(3)},
+	}
+
+	i := glox.NewInterpreter(io.Discard)
+	for _, tt := range tests {
+		toks, err := glox.ScanString(tt.src)
+		if err != nil {
+			t.Fatalf("scan string: %s", err)
+		}
+
+		parser := glox.NewParser(toks)
+		stmts, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+
+		got, err := i.Eval(stmts)
+		if err != nil {
+			t.Fatalf("eval %q: %s", tt.src, err)
+		}
+		if got != tt.want {
+			t.Fatalf("Eval(%q) = %v but want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	toks, err := glox.ScanString("var a = 1; fun f() {} { var b = 2; }")
+	if err != nil {
+		t.Fatalf("scan string: %s", err)
+	}
+	stmts, err := glox.NewParser(toks).Parse()
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	i := glox.NewInterpreter(io.Discard)
+	err = i.Interpret(stmts)
+	if err != nil {
+		t.Fatalf("interpret: %s", err)
+	}
+
+	globals := i.Globals()
+	for _, name := range []string{"a", "f", "clock"} {
+		if _, ok := globals[name]; !ok {
+			t.Errorf("expected global %q in %v", name, globals)
+		}
+	}
+	if _, ok := globals["b"]; ok {
+		t.Errorf("expected local b to not be global")
+	}
+}
+
+func BenchmarkInterpret(b *testing.B) {
+	recurse, err := txtar.ParseFile("testdata/recurse.txt")
+	if err != nil {
+		b.Fatalf("txtar parse: %s", err)
+	}
+
+	benchmarks := []struct {
+		name string
+		src  string
+	}{
+		{name: "recurse", src: string(recurse.Files[0].Data)},
+		{name: "fib", src: `
+fun fib(n) {
+    if (n < 2) return n;
+    return fib(n - 1) + fib(n - 2);
+}
+fib(20);
+`},
+		{name: "loop", src: `
+var sum = 0;
+for (var i = 0; i < 10000; i = i + 1) {
+    if (i % 2 == 0) continue;
+    if (i > 9000) break;
+    sum = sum + i;
+}
+`},
+	}
+
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			toks, err := glox.ScanString(bb.src)
+			if err != nil {
+				b.Fatalf("scan string: %s", err)
+			}
+			stmts, err := glox.NewParser(toks).Parse()
+			if err != nil {
+				b.Fatalf("parse: %s", err)
+			}
+			i := glox.NewInterpreter(io.Discard)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := i.Interpret(stmts); err != nil {
+					b.Fatalf("interpret: %s", err)
+				}
+			}
+		})
+	}
+}
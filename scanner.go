@@ -1,7 +1,13 @@
 package glox
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -15,18 +21,23 @@ const (
 	PAREN_RIGHT
 	BRACE_LEFT
 	BRACE_RIGHT
+	BRACKET_LEFT
+	BRACKET_RIGHT
 	COMMA
+	COLON
 	DOT
 	DASH
 	PLUS
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
 
 	BANG
 	BANG_EQUAL
 	EQUAL
 	EQUAL_EQUAL
+	ARROW
 	GREATER
 	GREATER_EQUAL
 	LESS
@@ -36,10 +47,20 @@ const (
 	STRING
 	NUMBER
 
+	// An interpolated string "a ${x} b ${y} c" is scanned as
+	// INTERP_BEGIN `"a ${`, x, INTERP_MID `} b ${`, y, INTERP_END `} c"`.
+	INTERP_BEGIN
+	INTERP_MID
+	INTERP_END
+
 	AND
+	BREAK
+	CATCH
 	CLASS
+	CONTINUE
 	ELSE
 	FALSE
+	FINALLY
 	FUN
 	FOR
 	IF
@@ -49,7 +70,9 @@ const (
 	RETURN
 	SUPER
 	THIS
+	THROW
 	TRUE
+	TRY
 	VAR
 	WHILE
 )
@@ -59,22 +82,27 @@ var tokenTypes = map[TokenType]string{
 	EOF:     "EOF",
 	COMMENT: "COMMENT",
 
-	PAREN_LEFT:  "(",
-	PAREN_RIGHT: ")",
-	BRACE_LEFT:  "{",
-	BRACE_RIGHT: "}",
-	COMMA:       ",",
-	DOT:         ".",
-	DASH:        "-",
-	PLUS:        "+",
-	SEMICOLON:   ";",
-	SLASH:       "/",
-	STAR:        "*",
+	PAREN_LEFT:    "(",
+	PAREN_RIGHT:   ")",
+	BRACE_LEFT:    "{",
+	BRACE_RIGHT:   "}",
+	BRACKET_LEFT:  "[",
+	BRACKET_RIGHT: "]",
+	COMMA:         ",",
+	COLON:         ":",
+	DOT:           ".",
+	DASH:          "-",
+	PLUS:          "+",
+	SEMICOLON:     ";",
+	SLASH:         "/",
+	STAR:          "*",
+	PERCENT:       "%",
 
 	BANG:          "!",
 	BANG_EQUAL:    "!=",
 	EQUAL:         "=",
 	EQUAL_EQUAL:   "==",
+	ARROW:         "=>",
 	GREATER:       ">",
 	GREATER_EQUAL: ">=",
 	LESS:          "<",
@@ -84,41 +112,57 @@ var tokenTypes = map[TokenType]string{
 	STRING:     "STRING",
 	NUMBER:     "NUMBER",
 
-	AND:    "",
-	CLASS:  "",
-	ELSE:   "",
-	FALSE:  "",
-	FUN:    "",
-	FOR:    "",
-	IF:     "",
-	NIL:    "",
-	OR:     "",
-	PRINT:  "",
-	RETURN: "",
-	SUPER:  "",
-	THIS:   "",
-	TRUE:   "",
-	VAR:    "",
-	WHILE:  "",
+	INTERP_BEGIN: "INTERP_BEGIN",
+	INTERP_MID:   "INTERP_MID",
+	INTERP_END:   "INTERP_END",
+
+	AND:      "and",
+	BREAK:    "break",
+	CATCH:    "catch",
+	CLASS:    "class",
+	CONTINUE: "continue",
+	ELSE:     "else",
+	FALSE:    "false",
+	FINALLY:  "finally",
+	FUN:      "fun",
+	FOR:      "for",
+	IF:       "if",
+	NIL:      "nil",
+	OR:       "or",
+	PRINT:    "print",
+	RETURN:   "return",
+	SUPER:    "super",
+	THIS:     "this",
+	THROW:    "throw",
+	TRUE:     "true",
+	TRY:      "try",
+	VAR:      "var",
+	WHILE:    "while",
 }
 
 var keywords = map[string]TokenType{
-	"and":    AND,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"fun":    FUN,
-	"for":    FOR,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":      AND,
+	"break":    BREAK,
+	"catch":    CATCH,
+	"class":    CLASS,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"finally":  FINALLY,
+	"fun":      FUN,
+	"for":      FOR,
+	"if":       IF,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"throw":    THROW,
+	"true":     TRUE,
+	"try":      TRY,
+	"var":      VAR,
+	"while":    WHILE,
 }
 
 func (t TokenType) String() string {
@@ -132,81 +176,242 @@ type Token struct {
 	Literal string
 
 	Line int
+	// Column of the first rune, starting from 1.
+	Column int
+	// Offset and End in bytes of the token in the source, [Offset, End).
+	Offset, End int
+	// File the token was scanned from, if known.
+	File string
+	// Trivia around the token, only kept by ScanTrivia.
+	Trivia *Trivia
 }
 
 func (t *Token) String() string {
-	return fmt.Sprintf("[%d] %s: %q", t.Line, t.Kind, t.Literal)
+	return fmt.Sprintf("[%s] %s: %q", t.Pos(), t.Kind, t.Literal)
+}
+
+// Pos where t starts.
+func (t Token) Pos() Position {
+	return Position{File: t.File, Line: t.Line, Column: t.Column, Offset: t.Offset}
+}
+
+// EndPos just past the last rune of t.
+func (t Token) EndPos() Position {
+	p := t.Pos()
+	p.Offset = t.End
+	if i := strings.LastIndexByte(t.Literal, '\n'); i >= 0 {
+		p.Line += strings.Count(t.Literal, "\n")
+		p.Column = utf8.RuneCountInString(t.Literal[i:])
+	} else {
+		p.Column += utf8.RuneCountInString(t.Literal)
+	}
+	return p
+}
+
+// Position in a source file.
+type Position struct {
+	File   string
+	Line   int // Starting from 1.
+	Column int // In runes, starting from 1.
+	Offset int // In bytes, starting from 0.
+}
+
+// String like "file.lox:12:7", or "12:7" without a file.
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
-func isDigit(b byte) bool        { return '0' <= b && b <= '9' }
-func isAlpha(b byte) bool        { return 'a' <= b && b <= 'z' || 'A' <= b && b < 'Z' || b == '_' }
-func isAlphaNumeric(b byte) bool { return isDigit(b) || isAlpha(b) }
+// Numbers are ASCII, but identifiers are like in Go: a letter or '_'
+// followed by letters, digits or '_', where letters and digits are what
+// Unicode says they are.
+func isDigit(r rune) bool        { return '0' <= r && r <= '9' }
+func isHexDigit(r rune) bool     { return isDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F' }
+func isBinaryDigit(r rune) bool  { return r == '0' || r == '1' }
+func isAlpha(r rune) bool        { return unicode.IsLetter(r) || r == '_' }
+func isAlphaNumeric(r rune) bool { return isAlpha(r) || unicode.IsDigit(r) }
 
 // Scanner inspired by Crafting Interpreters and Go.
 type Scanner struct {
-	// Can only be scanned once, which might be a problem?
-	// A []byte could be nicer.
-	src []byte
+	// src being scanned, starting at offset base in the source.
+	// Reading from r, src only keeps the token being scanned.
+	src  []byte
+	base int
+	// r to read more src from, nil when all of it is in src.
+	r io.Reader
+	// rerr reading r, other than io.EOF.
+	rerr error
+
+	// file name put on tokens, if any.
+	file string
 
 	// Scanner state.
 	// at the next byte to read.
 	at int
 	// line of at, starting from 1.
 	line int
+	// col of at in runes, starting from 1.
+	col int
+
+	// interps has an entry for each interpolation being scanned, counting
+	// the '{' to match before a '}' continues the string.
+	interps []int
+
+	// err explaining the last ILLEGAL token.
+	err *ScanError
 }
 
 func NewScanner(src []byte) *Scanner {
-	return &Scanner{src: src, line: 1}
+	return NewFileScanner("", src)
+}
+
+// NewFileScanner of src, which was read from file.
+func NewFileScanner(file string, src []byte) *Scanner {
+	return &Scanner{src: src, file: file, line: 1, col: 1}
+}
+
+// readSize of each read from a reader.
+const readSize = 4096
+
+// NewReaderScanner of source read from r, which came from file.
+// The source is read as tokens are scanned, and only the current token is
+// kept in memory, so it never needs to fit all at once. Scan only reads
+// past a newline when a token is not complete before it.
+func NewReaderScanner(file string, r io.Reader) *Scanner {
+	return &Scanner{src: make([]byte, 0, readSize), r: r, file: file, line: 1, col: 1}
+}
+
+// Err explains the last ILLEGAL token returned by Scan, or nil.
+// Failing to read the source ends it with an ILLEGAL token too.
+func (s *Scanner) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.rerr
+}
+
+// pos of the next byte to read.
+func (s *Scanner) pos() Position {
+	return Position{File: s.file, Line: s.line, Column: s.col, Offset: s.at}
+}
+
+// rest of src from at, with at least n runes unless the source ends first.
+func (s *Scanner) rest(n int) []byte {
+	for s.r != nil && !fullRunes(s.src[s.at-s.base:], n) {
+		if len(s.src) == cap(s.src) {
+			// The token is longer than the buffer.
+			src := make([]byte, len(s.src), 2*cap(s.src))
+			copy(src, s.src)
+			s.src = src
+		}
+		m, err := s.r.Read(s.src[len(s.src):cap(s.src)])
+		s.src = s.src[:len(s.src)+m]
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.rerr = err
+			}
+			s.r = nil
+		}
+	}
+	return s.src[s.at-s.base:]
+}
+
+// fullRunes if b starts with n runes.
+func fullRunes(b []byte, n int) bool {
+	for ; n > 0; n-- {
+		if !utf8.FullRune(b) {
+			return false
+		}
+		_, size := utf8.DecodeRune(b)
+		b = b[size:]
+	}
+	return true
+}
+
+// text from start up to at.
+func (s *Scanner) text(start int) string {
+	return string(s.src[start-s.base : s.at-s.base])
+}
+
+// forget what has been scanned so far, unless it is all in memory anyway.
+func (s *Scanner) forget() {
+	if s.r == nil {
+		return
+	}
+	n := copy(s.src, s.src[s.at-s.base:])
+	s.src = s.src[:n]
+	s.base = s.at
 }
 
-func (s *Scanner) advance() byte {
-	b := s.src[s.at]
-	if b == '\n' {
+// advance past the next rune, reporting it if it is not valid UTF-8.
+func (s *Scanner) advance() rune {
+	rest := s.rest(1)
+	r, size := utf8.DecodeRune(rest)
+	if r == utf8.RuneError && size == 1 && s.err == nil {
+		s.err = &ScanError{
+			Pos:  s.pos(),
+			End:  Position{File: s.file, Line: s.line, Column: s.col + 1, Offset: s.at + 1},
+			Code: CodeInvalidUTF8,
+			Msg:  fmt.Sprintf("Invalid UTF-8 byte %#x.", rest[0]),
+		}
+	}
+	if r == '\n' {
 		s.line += 1
+		s.col = 0
 	}
-	s.at += 1
-	return b
+	s.at += size
+	s.col += 1
+	return r
 }
 
-func (s *Scanner) peek() byte {
+func (s *Scanner) peek() rune {
 	if s.finished() {
-		return byte(0)
+		return 0
 	}
-	return s.src[s.at]
+	r, _ := utf8.DecodeRune(s.rest(1))
+	return r
 }
 
-func (s *Scanner) peekpeek() byte {
-	if s.at+1 >= len(s.src) {
-		return byte(0)
+func (s *Scanner) peekpeek() rune {
+	if s.finished() {
+		return 0
 	}
-	return s.src[s.at+1]
+	rest := s.rest(2)
+	_, size := utf8.DecodeRune(rest)
+	if size >= len(rest) {
+		return 0
+	}
+	r, _ := utf8.DecodeRune(rest[size:])
+	return r
 }
 
 func (s *Scanner) skip() {
 	if s.finished() {
 		return
 	}
-	if s.src[s.at] == '\n' {
-		s.line += 1
-	}
-	s.at += 1
+	s.advance()
 }
 
-// consume b if it is the next byte to scan.
-func (s *Scanner) consume(b byte) bool {
-	if s.peek() != b {
+// consume r if it is the next rune to scan.
+func (s *Scanner) consume(r rune) bool {
+	if s.peek() != r {
 		return false
 	}
 	s.skip()
 	return true
 }
 
-// finished if there are no more bytes.
+// finished if there are no more runes.
 func (s *Scanner) finished() bool {
-	return s.at >= len(s.src)
+	return len(s.rest(1)) == 0
 }
 
 func (s *Scanner) Scan() Token {
+	s.err = nil
+	s.forget()
+
 	// Skip whitespace so s is at some non-whitespace byte.
 whitespace:
 	for {
@@ -218,26 +423,22 @@ whitespace:
 		}
 	}
 	if s.finished() {
-		return Token{Kind: EOF, Line: s.line}
+		kind := EOF
+		if s.rerr != nil {
+			kind = ILLEGAL
+		}
+		return Token{Kind: kind, Line: s.line, Column: s.col, Offset: s.at, End: s.at, File: s.file}
 	}
 
 	start := s.at
 	line := s.line
+	col := s.col
 
 	var kind TokenType
 	b := s.advance()
 	switch {
 	case isDigit(b):
-		kind = NUMBER
-		for isDigit(s.peek()) {
-			s.skip()
-		}
-		if s.peek() == '.' && isDigit(s.peekpeek()) {
-			s.skip() // eat the .
-			for isDigit(s.peek()) {
-				s.skip()
-			}
-		}
+		kind = s.scanNumber(start, line, col)
 
 	case isAlpha(b):
 		kind = IDENTIFIER
@@ -245,7 +446,7 @@ whitespace:
 			s.skip()
 		}
 
-		if k, ok := keywords[string(s.src[start:s.at])]; ok {
+		if k, ok := keywords[s.text(start)]; ok {
 			kind = k
 		}
 
@@ -257,10 +458,28 @@ whitespace:
 			kind = PAREN_RIGHT
 		case '{':
 			kind = BRACE_LEFT
+			if n := len(s.interps); n > 0 {
+				s.interps[n-1]++
+			}
 		case '}':
 			kind = BRACE_RIGHT
+			if n := len(s.interps); n > 0 {
+				if s.interps[n-1] == 0 {
+					// Back in the string around the interpolation.
+					s.interps = s.interps[:n-1]
+					kind = s.scanString(INTERP_END, INTERP_MID, start, line, col)
+				} else {
+					s.interps[n-1]--
+				}
+			}
+		case '[':
+			kind = BRACKET_LEFT
+		case ']':
+			kind = BRACKET_RIGHT
 		case ',':
 			kind = COMMA
+		case ':':
+			kind = COLON
 		case '.':
 			kind = DOT
 		case '-':
@@ -271,6 +490,8 @@ whitespace:
 			kind = SEMICOLON
 		case '*':
 			kind = STAR
+		case '%':
+			kind = PERCENT
 
 		case '!':
 			if s.consume('=') {
@@ -281,6 +502,8 @@ whitespace:
 		case '=':
 			if s.consume('=') {
 				kind = EQUAL_EQUAL
+			} else if s.consume('>') {
+				kind = ARROW
 			} else {
 				kind = EQUAL
 			}
@@ -303,49 +526,353 @@ whitespace:
 				for s.peek() != '\n' && !s.finished() {
 					s.skip()
 				}
+			} else if s.consume('*') {
+				kind = s.scanBlockComment(start, line, col)
 			} else {
 				kind = SLASH
 			}
 
 		case '"':
-			kind = STRING
-			for s.peek() != '"' && !s.finished() {
+			kind = s.scanString(STRING, INTERP_BEGIN, start, line, col)
+
+		default:
+			if s.err == nil {
+				s.err = &ScanError{
+					Pos:  Position{File: s.file, Line: line, Column: col, Offset: start},
+					End:  s.pos(),
+					Code: CodeUnexpectedChar,
+					Msg:  fmt.Sprintf("Unexpected character %q.", s.text(start)),
+				}
+			}
+		}
+	}
+	if s.err != nil {
+		// Like invalid UTF-8 in a comment.
+		kind = ILLEGAL
+	}
+
+	return Token{
+		Kind:    kind,
+		Literal: s.text(start),
+		Line:    line,
+		Column:  col,
+		Offset:  start,
+		End:     s.at,
+		File:    s.file,
+	}
+}
+
+// scanNumber after its first digit, which started at start on line and col.
+// Letters and digits right after a number are part of it, so 12ab is one
+// invalid number rather than 12 followed by ab.
+func (s *Scanner) scanNumber(start, line, col int) TokenType {
+	prefixed := s.text(start) == "0" && strings.ContainsRune("xXbB", s.peek())
+	dot := false
+	for {
+		c := s.peek()
+		switch {
+		case isAlphaNumeric(c):
+			s.skip()
+			// Only a decimal exponent takes a sign.
+			if (c == 'e' || c == 'E') && !prefixed && (s.peek() == '+' || s.peek() == '-') && isDigit(s.peekpeek()) {
 				s.skip()
 			}
-			if s.finished() {
-				// TODO: Error handling.
-				// TODO: Indicate upstream that we want more data?
-				// Would be nice when running as an interpreter.
-				// Mutliline-strings, functions etc. Needs to work both here and in the parser.
-				kind = ILLEGAL
-			} else {
-				s.skip() // closing "
+			continue
+		case c == '.' && !prefixed && !dot && isDigit(s.peekpeek()):
+			s.skip()
+			dot = true
+			continue
+		}
+		break
+	}
+
+	if _, err := parseNumber(s.text(start)); err != nil && s.err == nil {
+		s.err = &ScanError{
+			Pos:  Position{File: s.file, Line: line, Column: col, Offset: start},
+			End:  s.pos(),
+			Code: CodeInvalidNumber,
+			Msg:  err.Error(),
+		}
+		return ILLEGAL
+	}
+	return NUMBER
+}
+
+// parseNumber lit into an int64, or a float64 if it has a fraction or an
+// exponent. Integers can be written in hex 0xFF or binary 0b1010, and
+// underscores can separate digits like 1_000_000.
+func parseNumber(lit string) (any, error) {
+	invalid := fmt.Errorf("Invalid number %q.", lit)
+
+	base := 10
+	digits := lit
+	if len(lit) > 1 && lit[0] == '0' {
+		switch lit[1] {
+		case 'x', 'X':
+			base, digits = 16, lit[2:]
+		case 'b', 'B':
+			base, digits = 2, lit[2:]
+		}
+	}
+
+	// Underscores go between digits, never first, last or doubled.
+	isDigitIn := map[int]func(rune) bool{2: isBinaryDigit, 10: isDigit, 16: isHexDigit}[base]
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '_' && (i == 0 || i == len(digits)-1 || !isDigitIn(rune(digits[i-1])) || !isDigitIn(rune(digits[i+1]))) {
+			return nil, invalid
+		}
+	}
+	digits = strings.ReplaceAll(digits, "_", "")
+	if digits == "" {
+		return nil, invalid
+	}
+
+	if base == 10 && strings.ContainsAny(digits, ".eE") {
+		f, err := strconv.ParseFloat(digits, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("Number %q is out of range.", lit)
+		}
+		if err != nil {
+			return nil, invalid
+		}
+		return f, nil
+	}
+
+	n, err := strconv.ParseInt(digits, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("Integer %q does not fit in 64 bits.", lit)
+	}
+	if err != nil {
+		return nil, invalid
+	}
+	return n, nil
+}
+
+// scanBlockComment after its opening "/*", which nests.
+// The token started at start on line and col.
+func (s *Scanner) scanBlockComment(start, line, col int) TokenType {
+	depth := 1
+	for depth > 0 && !s.finished() {
+		switch {
+		case s.peek() == '/' && s.peekpeek() == '*':
+			s.skip()
+			s.skip()
+			depth++
+		case s.peek() == '*' && s.peekpeek() == '/':
+			s.skip()
+			s.skip()
+			depth--
+		default:
+			s.skip()
+		}
+	}
+	if depth > 0 {
+		s.err = &ScanError{
+			Pos:  Position{File: s.file, Line: line, Column: col, Offset: start},
+			End:  s.pos(),
+			Code: CodeUnterminatedComment,
+			Msg:  "Unterminated comment.",
+		}
+		return ILLEGAL
+	}
+	return COMMENT
+}
+
+// scanString after its opening '"', or the '}' closing an interpolation.
+// It is closed if it ends with a '"', or interp if it ends with a "${".
+// The token started at start on line and col.
+func (s *Scanner) scanString(closed, interp TokenType, start, line, col int) TokenType {
+	valid := true
+	for !s.finished() {
+		switch {
+		case s.peek() == '"':
+			s.skip()
+			if !valid {
+				return ILLEGAL
+			}
+			return closed
+
+		case s.peek() == '$' && s.peekpeek() == '{':
+			s.skip()
+			s.skip()
+			s.interps = append(s.interps, 0)
+			if !valid {
+				return ILLEGAL
+			}
+			return interp
+
+		case s.peek() == '\\':
+			if !s.scanEscape() {
+				valid = false
 			}
 
 		default:
-			// Unexpected character.
-			kind = ILLEGAL
+			s.skip()
 		}
 	}
 
-	// TODO: Test Line and Literal.
-	return Token{Kind: kind, Line: line, Literal: string(s.src[start:s.at])}
+	// An invalid escape will not go away with more input.
+	if s.err == nil {
+		s.err = &ScanError{
+			Pos:  Position{File: s.file, Line: line, Column: col, Offset: start},
+			End:  s.pos(),
+			Code: CodeUnterminatedString,
+			Msg:  "Unterminated string.",
+		}
+	}
+	return ILLEGAL
+}
+
+// scanEscape starting at a '\' inside a string, returning false if it is
+// invalid. Only the first invalid escape of a string is kept in s.err.
+func (s *Scanner) scanEscape() bool {
+	pos := s.pos()
+	s.skip() // the \
+
+	var msg string
+	switch c := s.peek(); c {
+	case 'n', 't', '"', '\\', '$':
+		s.skip()
+		return true
+	case 'u':
+		s.skip()
+		if !s.consume('{') {
+			msg = `Expected '{' after \u.`
+			break
+		}
+		digits := s.at
+		for isHexDigit(s.peek()) {
+			s.skip()
+		}
+		hex := s.text(digits)
+		if !s.consume('}') || hex == "" || len(hex) > 6 {
+			msg = `Expected 1 to 6 hex digits in \u{...}.`
+			break
+		}
+		r, _ := strconv.ParseUint(hex, 16, 32)
+		if !utf8.ValidRune(rune(r)) {
+			msg = fmt.Sprintf("Invalid code point U+%X.", r)
+			break
+		}
+		return true
+	case 0:
+		if s.finished() {
+			// Reported as an unterminated string.
+			return true
+		}
+		fallthrough
+	default:
+		s.skip()
+		msg = fmt.Sprintf(`Unknown escape sequence '\%c'.`, c)
+	}
+
+	if s.err == nil {
+		s.err = &ScanError{Pos: pos, End: s.pos(), Code: CodeInvalidEscape, Msg: msg}
+	}
+	return false
+}
+
+// unescape the text between the quotes of a string literal.
+func unescape(lit string) (string, error) {
+	if !strings.Contains(lit, `\`) {
+		return lit, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '\\' {
+			sb.WriteByte(lit[i])
+			continue
+		}
+		i++
+		if i == len(lit) {
+			return "", errors.New("trailing backslash")
+		}
+		switch lit[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\\', '$':
+			sb.WriteByte(lit[i])
+		case 'u':
+			end := strings.IndexByte(lit[i:], '}')
+			if !strings.HasPrefix(lit[i:], "u{") || end < 0 {
+				return "", fmt.Errorf("bad escape at %d", i-1)
+			}
+			r, err := strconv.ParseUint(lit[i+2:i+end], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("bad escape at %d", i-1)
+			}
+			sb.WriteRune(rune(r))
+			i += end
+		default:
+			return "", fmt.Errorf("bad escape at %d", i-1)
+		}
+	}
+	return sb.String(), nil
 }
 
 func ScanBytes(bs []byte) ([]Token, error) {
-	sc := NewScanner(bs)
+	return ScanFile("", bs)
+}
+
+// ScanFile like ScanBytes, but tokens are positioned in file.
+// Comments are dropped, as the parser has no use for them.
+func ScanFile(file string, bs []byte) ([]Token, error) {
+	return scanFile(file, bs, false)
+}
+
+// Trivia around a token which does not affect the program.
+type Trivia struct {
+	// Leading comments on the lines before the token.
+	Leading []Token
+	// Trailing comments on the same line after the token.
+	Trailing []Token
+}
+
+// ScanTrivia like ScanFile, but comments are kept as Trivia on the tokens
+// around them. Comments at the end of the source lead the EOF token.
+func ScanTrivia(file string, bs []byte) ([]Token, error) {
+	return scanFile(file, bs, true)
+}
+
+func scanFile(file string, bs []byte, trivia bool) ([]Token, error) {
+	sc := NewFileScanner(file, bs)
 
 	toks := []Token{}
+	var leading []Token
+	// line the last token, or a comment trailing it, ends on.
+	line := 0
 	for {
 		tok := sc.Scan()
 		if tok.Kind == ILLEGAL {
-			return nil, fmt.Errorf("ILLEGAL token encountered: %+v", tok)
+			return nil, sc.Err()
 		}
+		if tok.Kind == COMMENT {
+			if !trivia {
+				continue
+			}
+			if n := len(toks); n > 0 && leading == nil && tok.Line == line {
+				prev := &toks[n-1]
+				if prev.Trivia == nil {
+					prev.Trivia = &Trivia{}
+				}
+				prev.Trivia.Trailing = append(prev.Trivia.Trailing, tok)
+			} else {
+				leading = append(leading, tok)
+			}
+			line = tok.EndPos().Line
+			continue
+		}
+		if leading != nil {
+			tok.Trivia = &Trivia{Leading: leading}
+			leading = nil
+		}
+		toks = append(toks, tok)
+		line = tok.EndPos().Line
 		if tok.Kind == EOF {
-			toks = append(toks, tok)
 			break
 		}
-		toks = append(toks, tok)
 	}
 	return toks, nil
 }
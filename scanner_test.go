@@ -1,9 +1,18 @@
 package glox_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/vikblom/glox"
+	"golang.org/x/tools/txtar"
 )
 
 func TestScannerKinds(t *testing.T) {
@@ -28,6 +37,8 @@ func TestScannerKinds(t *testing.T) {
 		// 1 or 2 chars
 		{"/", glox.SLASH},
 		{"//", glox.COMMENT},
+		{"/**/", glox.COMMENT},
+		{"/* a /* b */ c */", glox.COMMENT},
 		{"!", glox.BANG},
 		{"!=", glox.BANG_EQUAL},
 		{"=", glox.EQUAL},
@@ -44,9 +55,19 @@ func TestScannerKinds(t *testing.T) {
 		{"1", glox.NUMBER},
 		{"1.23", glox.NUMBER},
 		{"0.23", glox.NUMBER},
+		{"1_000", glox.NUMBER},
+		{"1e9", glox.NUMBER},
+		{"1.5E-9", glox.NUMBER},
+		{"0xFF", glox.NUMBER},
+		{"0b1010", glox.NUMBER},
+		{"%", glox.PERCENT},
 
 		{"foo", glox.IDENTIFIER},
 		{"_foo", glox.IDENTIFIER},
+		{"Zed", glox.IDENTIFIER},
+		{"größe", glox.IDENTIFIER},
+		{"日本", glox.IDENTIFIER},
+		{"x١", glox.IDENTIFIER},
 
 		// Keywords
 		{"and", glox.AND},
@@ -106,6 +127,9 @@ func TestScannerTokens(t *testing.T) {
 			want: glox.Token{
 				Kind:    glox.BRACE_LEFT,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     1,
 				Literal: "{",
 			},
 		},
@@ -114,6 +138,9 @@ func TestScannerTokens(t *testing.T) {
 			want: glox.Token{
 				Kind:    glox.BRACE_LEFT,
 				Line:    2,
+				Column:  1,
+				Offset:  1,
+				End:     2,
 				Literal: "{",
 			},
 		},
@@ -122,6 +149,9 @@ func TestScannerTokens(t *testing.T) {
 			want: glox.Token{
 				Kind:    glox.COMMENT,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     6,
 				Literal: "// foo",
 			},
 		},
@@ -130,6 +160,9 @@ func TestScannerTokens(t *testing.T) {
 			want: glox.Token{
 				Kind:    glox.COMMENT,
 				Line:    2,
+				Column:  5,
+				Offset:  5,
+				End:     15,
 				Literal: "// foo bar",
 			},
 		},
@@ -138,6 +171,9 @@ func TestScannerTokens(t *testing.T) {
 			want: glox.Token{
 				Kind:    glox.STRING,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     5,
 				Literal: `"foo"`,
 			},
 		},
@@ -146,8 +182,11 @@ func TestScannerTokens(t *testing.T) {
 			src: `"foo
 bar"`,
 			want: glox.Token{
-				Kind: glox.STRING,
-				Line: 1,
+				Kind:   glox.STRING,
+				Line:   1,
+				Column: 1,
+				Offset: 0,
+				End:    9,
 				Literal: `"foo
 bar"`,
 			},
@@ -157,6 +196,9 @@ bar"`,
 			want: glox.Token{
 				Kind:    glox.NUMBER,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     4,
 				Literal: "1.23",
 			},
 		},
@@ -167,6 +209,9 @@ bar"`,
 			want: glox.Token{
 				Kind:    glox.NUMBER,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     3,
 				Literal: "123",
 			},
 		},
@@ -177,6 +222,9 @@ bar"`,
 			want: glox.Token{
 				Kind:    glox.DOT,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     1,
 				Literal: ".",
 			},
 		},
@@ -185,6 +233,9 @@ bar"`,
 			want: glox.Token{
 				Kind:    glox.IDENTIFIER,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     3,
 				Literal: `foo`,
 			},
 		},
@@ -193,6 +244,9 @@ bar"`,
 			want: glox.Token{
 				Kind:    glox.IDENTIFIER,
 				Line:    1,
+				Column:  1,
+				Offset:  0,
+				End:     4,
 				Literal: "_foo",
 			},
 		},
@@ -215,6 +269,18 @@ func TestScanUnclosedString(t *testing.T) {
 	}
 }
 
+func TestScanBytesUnclosedString(t *testing.T) {
+	_, err := glox.ScanString(`print "foo`)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("scanning unclosed string should be unexpected EOF, but got: %v", err)
+	}
+
+	_, err = glox.ScanString(`print #`)
+	if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("scanning illegal char should fail, but got: %v", err)
+	}
+}
+
 func TestScanMany(t *testing.T) {
 	src := []byte(`
 // this is a comment
@@ -239,3 +305,365 @@ func TestScanMany(t *testing.T) {
 		t.Errorf("expected 17 tokens but got %d in:\n%q", len(toks), src)
 	}
 }
+
+func TestScanPositions(t *testing.T) {
+	src := "var a = 1;\nprint  a;"
+	toks, err := glox.ScanFile("test.lox", []byte(src))
+	if err != nil {
+		t.Fatalf("scan: %s", err)
+	}
+
+	want := []string{
+		"test.lox:1:1", "test.lox:1:5", "test.lox:1:7", "test.lox:1:9", "test.lox:1:10",
+		"test.lox:2:1", "test.lox:2:8", "test.lox:2:9",
+		"test.lox:2:10",
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("expected %d tokens but got %d", len(want), len(toks))
+	}
+	for i, tok := range toks {
+		if got := tok.Pos().String(); got != want[i] {
+			t.Errorf("token %d %q at %s but want %s", i, tok.Literal, got, want[i])
+		}
+		if got := src[tok.Offset:tok.End]; got != tok.Literal {
+			t.Errorf("token %d %q spans %q", i, tok.Literal, got)
+		}
+	}
+}
+
+func TestScanEscapes(t *testing.T) {
+	tests := []struct {
+		src string
+		pos string
+		msg string
+	}{
+		{`"a\qb"`, "1:3", `Unknown escape sequence '\q'.`},
+		{`"\u0041"`, "1:2", `Expected '{' after \u.`},
+		{`"\u{}"`, "1:2", `Expected 1 to 6 hex digits in \u{...}.`},
+		{`"\u{1234567}"`, "1:2", `Expected 1 to 6 hex digits in \u{...}.`},
+		{`"\u{D800}"`, "1:2", "Invalid code point U+D800."},
+		{`"\u{110000}"`, "1:2", "Invalid code point U+110000."},
+		{"\"one\ntwo \\x\"", "2:5", `Unknown escape sequence '\x'.`},
+		// Only the first is reported.
+		{`"\a\b"`, "1:2", `Unknown escape sequence '\a'.`},
+	}
+
+	for _, tt := range tests {
+		_, err := glox.ScanString(tt.src)
+		var se *glox.ScanError
+		if !errors.As(err, &se) {
+			t.Errorf("ScanString(%q) = %v, want a ScanError", tt.src, err)
+			continue
+		}
+		if se.Code != glox.CodeInvalidEscape || se.Pos.String() != tt.pos || se.Msg != tt.msg {
+			t.Errorf("ScanString(%q) = %s %s: %s, want %s %s: %s",
+				tt.src, se.Code, se.Pos, se.Msg, glox.CodeInvalidEscape, tt.pos, tt.msg)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("ScanString(%q) should not be unexpected EOF", tt.src)
+		}
+	}
+
+	// An escaped quote does not end the string.
+	_, err := glox.ScanString(`"foo\"`)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf(`scanning "foo\" should be unexpected EOF, but got: %v`, err)
+	}
+}
+
+func TestScanMultilineString(t *testing.T) {
+	toks, err := glox.ScanString("var s = \"a\nb\n\\\"c\\\"\";\nprint s;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	str, semi, print := toks[3], toks[4], toks[5]
+	if str.Kind != glox.STRING || str.Pos().String() != "1:9" || str.EndPos().String() != "3:7" {
+		t.Errorf("string %v ends at %s, want 1:9 to 3:7", &str, str.EndPos())
+	}
+	if semi.Pos().String() != "3:7" || print.Pos().String() != "4:1" {
+		t.Errorf("tokens after string at %s and %s, want 3:7 and 4:1", semi.Pos(), print.Pos())
+	}
+}
+
+func TestScanInterpolation(t *testing.T) {
+	toks, err := glox.ScanString(`"a ${ {x} } b ${"c${y}"} d"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind glox.TokenType
+		lit  string
+	}{
+		{glox.INTERP_BEGIN, `"a ${`},
+		{glox.BRACE_LEFT, `{`},
+		{glox.IDENTIFIER, `x`},
+		{glox.BRACE_RIGHT, `}`},
+		{glox.INTERP_MID, `} b ${`},
+		{glox.INTERP_BEGIN, `"c${`},
+		{glox.IDENTIFIER, `y`},
+		{glox.INTERP_END, `}"`},
+		{glox.INTERP_END, `} d"`},
+		{glox.EOF, ``},
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("got %d tokens but want %d: %v", len(toks), len(want), toks)
+	}
+	for i, w := range want {
+		if toks[i].Kind != w.kind || toks[i].Literal != w.lit {
+			t.Errorf("token %d = %v but want %s %q", i, &toks[i], w.kind, w.lit)
+		}
+	}
+
+	_, err = glox.ScanString(`"a ${b} c`)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("scanning unclosed interpolated string should be unexpected EOF, but got: %v", err)
+	}
+}
+
+func TestScanBlockComments(t *testing.T) {
+	toks, err := glox.ScanString("a /* b /* c */\n d */ e // f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The parser gets no comments.
+	if len(toks) != 3 || toks[0].Literal != "a" || toks[1].Literal != "e" || toks[2].Kind != glox.EOF {
+		t.Errorf("got tokens %v but want a, e and EOF", toks)
+	}
+	if got := toks[1].Pos().String(); got != "2:7" {
+		t.Errorf("token after comment at %s but want 2:7", got)
+	}
+
+	for _, src := range []string{"/* a", "/* a /* b */"} {
+		_, err = glox.ScanString(src)
+		var se *glox.ScanError
+		if !errors.As(err, &se) || se.Code != glox.CodeUnterminatedComment || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("scanning %q should be an unterminated comment, but got: %v", src, err)
+		}
+	}
+}
+
+func TestScanTrivia(t *testing.T) {
+	src := `// Leads var.
+/* Also leads var. */ var a = 1; // Trails ;
+// Leads print.
+print a; /* Trails ; */ // Trails ; too.
+/* Leads EOF. */
+`
+	toks, err := glox.ScanTrivia("", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comments := func(l []glox.Token) []string {
+		var lits []string
+		for _, tok := range l {
+			lits = append(lits, tok.Literal)
+		}
+		return lits
+	}
+	got := map[string][2][]string{}
+	for _, tok := range toks {
+		if tok.Trivia != nil {
+			key := fmt.Sprintf("%s@%s", tok.Kind, tok.Pos())
+			got[key] = [2][]string{comments(tok.Trivia.Leading), comments(tok.Trivia.Trailing)}
+		}
+	}
+	want := map[string][2][]string{
+		"var@2:23":  {{"// Leads var.", "/* Also leads var. */"}, nil},
+		";@2:32":    {nil, {"// Trails ;"}},
+		"print@4:1": {{"// Leads print."}, nil},
+		";@4:8":     {nil, {"/* Trails ; */", "// Trails ; too."}},
+		"EOF@6:1":   {{"/* Leads EOF. */"}, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got trivia\n%v\nbut want\n%v", got, want)
+	}
+
+	// The same tokens parse.
+	if _, err := glox.NewParser(toks).Parse(); err != nil {
+		t.Errorf("parse tokens with trivia: %s", err)
+	}
+}
+
+func TestScanNumbers(t *testing.T) {
+	valid := []string{"0", "007", "1_000_000", "1.5", "1e9", "1e+9", "1E-9", "1.5e3", "0xff", "0XdeadBEEF", "0x7FFF_FFFF", "0b1010", "0B1_0"}
+	for _, src := range valid {
+		toks, err := glox.ScanString(src)
+		if err != nil || toks[0].Kind != glox.NUMBER || toks[0].Literal != src {
+			t.Errorf("ScanString(%q) = %v, %v but want one number", src, toks, err)
+		}
+	}
+
+	invalid := []struct {
+		src, msg string
+	}{
+		{"1_", `Invalid number "1_".`},
+		{"1__0", `Invalid number "1__0".`},
+		{"0x", `Invalid number "0x".`},
+		{"0x_1", `Invalid number "0x_1".`},
+		{"0b102", `Invalid number "0b102".`},
+		{"12ab", `Invalid number "12ab".`},
+		{"1e", `Invalid number "1e".`},
+		{"1.5e+", `Invalid number "1.5e".`},
+		{"1e999", `Number "1e999" is out of range.`},
+		{"9223372036854775808", `Integer "9223372036854775808" does not fit in 64 bits.`},
+	}
+	for _, tt := range invalid {
+		_, err := glox.ScanString(tt.src)
+		var se *glox.ScanError
+		if !errors.As(err, &se) || se.Code != glox.CodeInvalidNumber || se.Msg != tt.msg {
+			t.Errorf("ScanString(%q) = %v but want %s", tt.src, err, tt.msg)
+		}
+	}
+}
+
+func TestScanUnicode(t *testing.T) {
+	toks, err := glox.ScanString("var größe = \"日本\";\nπ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		lit      string
+		pos, end string
+	}{
+		{"var", "1:1", "1:4"},
+		{"größe", "1:5", "1:10"},
+		{"=", "1:11", "1:12"},
+		{`"日本"`, "1:13", "1:17"},
+		{";", "1:17", "1:18"},
+		{"π", "2:1", "2:2"},
+	}
+	for i, w := range want {
+		tok := toks[i]
+		if tok.Literal != w.lit || tok.Pos().String() != w.pos || tok.EndPos().String() != w.end {
+			t.Errorf("token %d = %v ending at %s but want %q at %s to %s", i, &tok, tok.EndPos(), w.lit, w.pos, w.end)
+		}
+	}
+
+	// Not letters.
+	for _, src := range []string{"1١", "€", "·"} {
+		if _, err := glox.ScanString(src); err == nil {
+			t.Errorf("ScanString(%q) should fail", src)
+		}
+	}
+}
+
+func TestScanInvalidUTF8(t *testing.T) {
+	for _, src := range []string{"a;\n  b\xffc;", "a;\n  /* \xff */", "a;\n  \"\xff\""} {
+		_, err := glox.ScanString(src)
+		var se *glox.ScanError
+		if !errors.As(err, &se) || se.Code != glox.CodeInvalidUTF8 {
+			t.Errorf("ScanString(%q) = %v but want invalid UTF-8", src, err)
+			continue
+		}
+		if se.Pos.Line != 2 || se.Pos.Offset != strings.IndexByte(src, 0xff) {
+			t.Errorf("ScanString(%q) error at %s offset %d, want the 0xff byte", src, se.Pos, se.Pos.Offset)
+		}
+	}
+}
+
+func TestReaderScanner(t *testing.T) {
+	files, _ := filepath.Glob("testdata/*.txt")
+	for _, file := range files {
+		a, err := txtar.ParseFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := a.Files[0].Data
+		want, err := glox.ScanTrivia(file, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// One byte at a time, splitting runes.
+		sc := glox.NewReaderScanner(file, iotest.OneByteReader(bytes.NewReader(src)))
+		i := 0
+		for {
+			tok := sc.Scan()
+			if tok.Kind == glox.COMMENT {
+				continue
+			}
+			if i >= len(want) || tok != withoutTrivia(want[i]) {
+				t.Fatalf("%s: token %d = %v but want %v", file, i, &tok, &want[i])
+			}
+			i++
+			if tok.Kind == glox.EOF || tok.Kind == glox.ILLEGAL {
+				break
+			}
+		}
+	}
+}
+
+func withoutTrivia(tok glox.Token) glox.Token {
+	tok.Trivia = nil
+	return tok
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestReaderScannerBounded(t *testing.T) {
+	// Many statements, but no long tokens.
+	stmt := "var a = \"some string\"; /* comment */ print a + \"${1 + 2}\";\n"
+	r := &countingReader{r: strings.NewReader(strings.Repeat(stmt, 10000))}
+
+	sc := glox.NewReaderScanner("", r)
+	n := 0
+	for tok := sc.Scan(); tok.Kind != glox.EOF; tok = sc.Scan() {
+		if tok.Kind == glox.ILLEGAL {
+			t.Fatal(sc.Err())
+		}
+		// Never far ahead of the tokens.
+		if ahead := r.n - tok.End; ahead > 8192 {
+			t.Fatalf("read %d bytes ahead of token %d", ahead, n)
+		}
+		n++
+	}
+	if n != 10000*15 {
+		t.Errorf("scanned %d tokens but want %d", n, 10000*15)
+	}
+}
+
+// lineReader gives one line per Read, and fails if there are none left.
+type lineReader struct{ lines []string }
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, errors.New("read too far")
+	}
+	n := copy(p, r.lines[0])
+	r.lines = r.lines[1:]
+	return n, nil
+}
+
+func TestReaderScannerLines(t *testing.T) {
+	// Only tokens spanning lines need the next one.
+	r := &lineReader{lines: []string{"print 1.5 + a;\n", "print \"a\n", "b\";\n"}}
+	sc := glox.NewReaderScanner("", r)
+	var lits []string
+	for i := 0; i < 8; i++ {
+		tok := sc.Scan()
+		if tok.Kind == glox.ILLEGAL {
+			t.Fatalf("after %v: %s", lits, sc.Err())
+		}
+		lits = append(lits, tok.Literal)
+	}
+	want := []string{"print", "1.5", "+", "a", ";", "print", "\"a\nb\"", ";"}
+	if !reflect.DeepEqual(lits, want) {
+		t.Errorf("scanned %q but want %q", lits, want)
+	}
+
+	// Running out of lines is an error from the reader.
+	if tok := sc.Scan(); tok.Kind != glox.ILLEGAL || sc.Err() == nil || sc.Err().Error() != "read too far" {
+		t.Errorf("scan past the lines = %v, %v but want the read error", &tok, sc.Err())
+	}
+}
@@ -0,0 +1,85 @@
+package glox
+
+import (
+	"fmt"
+	"io"
+)
+
+// Value of Lox in Go. Lox has nil, bool, int64, float64 and string values,
+// as well as values like functions, classes, instances, lists and maps,
+// which Go can pass around but not look into.
+type Value = any
+
+// VM runs Lox from Go, keeping globals between runs, and lets Go call
+// functions defined in Lox:
+//
+//	vm := glox.NewVM(os.Stdout)
+//	_, err := vm.Eval(`fun greet(name) { return "Hello " + name; }`)
+//	greet, _ := vm.GetGlobal("greet")
+//	v, err := vm.Call(greet, "Go")
+type VM struct {
+	interp *Interpreter
+}
+
+// NewVM printing to out.
+func NewVM(out io.Writer) *VM {
+	return &VM{interp: NewInterpreter(out)}
+}
+
+// Eval src, returning the value of its last statement if it is an
+// expression statement. Errors are like from ScanString, Parse or Eval.
+func (vm *VM) Eval(src string) (Value, error) {
+	toks, err := ScanString(src)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := NewParser(toks).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return vm.interp.Eval(stmts)
+}
+
+// RegisterFunc fn as a global function, see Interpreter.RegisterFunc.
+func (vm *VM) RegisterFunc(name string, arity int, fn Func) {
+	vm.interp.RegisterFunc(name, arity, fn)
+}
+
+// GetGlobal variable name, if it is defined.
+func (vm *VM) GetGlobal(name string) (Value, bool) {
+	v, ok := vm.interp.global.vars[name]
+	return v, ok
+}
+
+// SetGlobal variable name to v, defining it if need be.
+// Go values are converted like RegisterStruct describes, so Go ints and
+// floats of any size become int64 and float64. Go values without a Lox
+// value, like funcs and channels, are a *RuntimeError.
+func (vm *VM) SetGlobal(name string, v Value) error {
+	lv, err := vm.interp.fromGo(v, "Can't set "+name)
+	if err != nil {
+		return err
+	}
+	vm.interp.global.define(name, lv)
+	return nil
+}
+
+// RegisterStruct binds a Go struct type as a class, see Interpreter.RegisterStruct.
+func (vm *VM) RegisterStruct(name string, ptr any) error {
+	return vm.interp.RegisterStruct(name, ptr)
+}
+
+// Call fn, a Lox function or class, with args like SetGlobal converts them.
+// Calling a class constructs an instance.
+// Errors are *RuntimeError, also if fn is not callable with args.
+func (vm *VM) Call(fn Value, args ...Value) (v Value, err error) {
+	defer vm.interp.recoverError(&err, len(vm.interp.calls), vm.interp.scope)
+
+	vals := make([]any, len(args))
+	for j, a := range args {
+		if vals[j], err = vm.interp.fromGo(a, fmt.Sprintf("Can't call with argument %d", j+1)); err != nil {
+			return nil, err
+		}
+	}
+	return vm.interp.callValue(Token{}, fn, vals), nil
+}
@@ -0,0 +1,218 @@
+package glox_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/vikblom/glox"
+)
+
+func TestVM(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	vm := glox.NewVM(buf)
+
+	_, err := vm.Eval(`
+var greeting = "Hello";
+fun greet(name) { return greeting + " " + name; }
+class Point {
+  init(x, y) { this.x = x; this.y = y; }
+  sum() { return this.x + this.y; }
+}
+`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+
+	greet, ok := vm.GetGlobal("greet")
+	if !ok {
+		t.Fatalf("expected global greet")
+	}
+	v, err := vm.Call(greet, "Go")
+	if err != nil {
+		t.Fatalf("call greet: %s", err)
+	}
+	if v != "Hello Go" {
+		t.Errorf(`Call(greet, "Go") = %v but want "Hello Go"`, v)
+	}
+
+	// Globals set from Go are seen by Lox, and the other way around.
+	vm.SetGlobal("greeting", "Hi")
+	v, err = vm.Eval(`greet("again");`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+	if v != "Hi again" {
+		t.Errorf(`Eval(greet("again")) = %v but want "Hi again"`, v)
+	}
+
+	// Go numbers become Lox numbers.
+	vm.SetGlobal("n", 2)
+	v, err = vm.Eval(`n * 1.5;`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+	if v != 3.0 {
+		t.Errorf("Eval(n * 1.5) = %v but want 3.0", v)
+	}
+
+	// Calling a class constructs an instance.
+	class, _ := vm.GetGlobal("Point")
+	p, err := vm.Call(class, 1, int32(2))
+	if err != nil {
+		t.Fatalf("call Point: %s", err)
+	}
+	vm.SetGlobal("p", p)
+	v, err = vm.Eval(`p.sum();`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+	if v != int64(3) {
+		t.Errorf("Eval(p.sum()) = %T %v but want int64 3", v, v)
+	}
+
+	if _, ok := vm.GetGlobal("nope"); ok {
+		t.Errorf("expected no global nope")
+	}
+}
+
+func TestVMCallErrors(t *testing.T) {
+	vm := glox.NewVM(io.Discard)
+	_, err := vm.Eval(`
+fun one(a) { return a; }
+fun fail() { return 1 + nil; }
+`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+	one, _ := vm.GetGlobal("one")
+	fail, _ := vm.GetGlobal("fail")
+
+	tests := []struct {
+		fn   glox.Value
+		args []glox.Value
+		code glox.ErrorCode
+		want string
+	}{
+		{fn: one, args: nil, code: glox.CodeArity, want: "Expected 1 arguments but got 0"},
+		{fn: "one", args: nil, code: glox.CodeNotCallable, want: "Not callable string"},
+		{fn: fail, args: nil, code: glox.CodeOperandType, want: `3:23: Operands of "+" must be two numbers or two strings: number and nil`},
+	}
+	for _, tt := range tests {
+		_, err := vm.Call(tt.fn, tt.args...)
+		var rerr *glox.RuntimeError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("Call(%v) = %v but want a runtime error", tt.fn, err)
+		}
+		if rerr.Code != tt.code || rerr.Error() != tt.want {
+			t.Errorf("Call(%v) = %s %q but want %s %q", tt.fn, rerr.Code, rerr, tt.code, tt.want)
+		}
+	}
+
+	// The stack ends where Go called in.
+	_, err = vm.Call(fail)
+	var rerr *glox.RuntimeError
+	if errors.As(err, &rerr) && len(rerr.Stack) != 1 {
+		t.Errorf("expected one frame but got %v", rerr.Stack)
+	}
+
+	// The VM works after errors.
+	v, err := vm.Call(one, true)
+	if err != nil || v != true {
+		t.Errorf("Call(one, true) = %v, %v but want true", v, err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	vm := glox.NewVM(buf)
+
+	vm.RegisterFunc("double", 1, func(args []glox.Value) (glox.Value, error) {
+		n, ok := args[0].(int64)
+		if !ok {
+			return nil, fmt.Errorf("double wants an integer, got %v", args[0])
+		}
+		return int(n) * 2, nil
+	})
+	vm.RegisterFunc("count", glox.Variadic, func(args []glox.Value) (glox.Value, error) {
+		return len(args), nil
+	})
+	errNotFound := errors.New("not found")
+	vm.RegisterFunc("lookup", 1, func(args []glox.Value) (glox.Value, error) {
+		return nil, errNotFound
+	})
+
+	_, err := vm.Eval(`
+print double(21);
+print count() + count(1, 2, 3);
+print double;
+try {
+  lookup("x");
+} catch (e) {
+  print e.message;
+}
+`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+	want := "42\n3\n<native fn double>\nnot found\n"
+	if got := buf.String(); got != want {
+		t.Errorf("printed %q but want %q", got, want)
+	}
+
+	// Errors are reported where Lox called, and unwrap to the Go error.
+	_, err = vm.Eval("var a = 1;\nprint a + lookup(a);")
+	var rerr *glox.RuntimeError
+	if !errors.As(err, &rerr) || rerr.Code != glox.CodeNative {
+		t.Fatalf("Eval(lookup(a)) = %v but want a native runtime error", err)
+	}
+	if got, want := rerr.Error(), "2:19: not found"; got != want {
+		t.Errorf("Eval(lookup(a)) = %q but want %q", got, want)
+	}
+	if !errors.Is(err, errNotFound) {
+		t.Errorf("expected %v to unwrap to %v", err, errNotFound)
+	}
+	if got, want := rerr.Traceback(), "at lookup (line 2)\nat <script> (line 2)"; got != want {
+		t.Errorf("Traceback() = %q but want %q", got, want)
+	}
+
+	_, err = vm.Eval(`double(1, 2);`)
+	if !errors.As(err, &rerr) || rerr.Code != glox.CodeArity {
+		t.Errorf("Eval(double(1, 2)) = %v but want an arity error", err)
+	}
+}
+
+func TestRegisterFuncCallback(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	vm := glox.NewVM(buf)
+
+	// guard calls back into Lox and returns any error as a value.
+	vm.RegisterFunc("guard", 1, func(args []glox.Value) (glox.Value, error) {
+		v, err := vm.Call(args[0])
+		var rerr *glox.RuntimeError
+		if errors.As(err, &rerr) {
+			return rerr.Code.String(), nil
+		}
+		return v, err
+	})
+
+	// The failing callback must not unwind the calls and scopes of outer.
+	_, err := vm.Eval(`
+fun outer() {
+  var x = "outer";
+  print guard(fun () { return 1 / 0; });
+  print x;
+  return guard(() => x + "!");
+}
+print outer();
+`)
+	if err != nil {
+		t.Fatalf("eval: %s", err)
+	}
+	want := "division-by-zero\nouter\nouter!\n"
+	if got := buf.String(); got != want {
+		t.Errorf("printed %q but want %q", got, want)
+	}
+}